@@ -0,0 +1,337 @@
+package main
+
+import "strings"
+
+// aiFlag is the ai-git flag that requests AI mode explicitly. It is never forwarded to git.
+const aiFlag = "--ai"
+
+// fromFlag is the ai-git flag that describes the work a new branch is for. It implies AI mode.
+const fromFlag = "--from"
+
+// splitFlag is the ai-git flag that splits the changes into several commits. It implies AI mode.
+const splitFlag = "--split"
+
+// explainFlag is the ai-git flag that annotates stash list entries with summaries. It implies AI mode.
+const explainFlag = "--explain"
+
+// suggestFlag is the ai-git flag that suggests the next release tag. It implies AI mode.
+const suggestFlag = "--suggest"
+
+// yesFlag and noEditFlag use the generated text without opening the editor and answer
+// confirmations with yes. dryRunFlag prints the generated text without running git.
+// They only affect AI mode and never imply it. --no-edit and --dry-run are git options
+// too, in AI mode they are taken by ai-git and not passed to git.
+const (
+	yesFlag    = "--yes"
+	noEditFlag = "--no-edit"
+	dryRunFlag = "--dry-run"
+)
+
+// commandSpec describes the options of a git command that ai-git needs to understand
+type commandSpec struct {
+	// valueOptions are the options that take a value in the next argument when
+	// no value is attached, e.g. "-m msg" or "--author name"
+	valueOptions map[string]bool
+	// aiOptions are value options that request AI mode when given without a value
+	aiOptions map[string]bool
+	// createOptions are the AI options that create a branch. With --ai they never
+	// take a value, so the next argument is the start point.
+	createOptions map[string]bool
+	// flags are the short options without a value, which can be combined like "-am"
+	flags map[string]bool
+	// optionalValueOptions are short options whose value is optional and can only be
+	// attached, e.g. "-S[<keyid>]"
+	optionalValueOptions map[string]bool
+	// acceptsFrom is set for commands that accept --from
+	acceptsFrom bool
+	// acceptsSplit is set for commands that accept --split
+	acceptsSplit bool
+	// acceptsExplain is set for commands that accept --explain
+	acceptsExplain bool
+	// acceptsSuggest is set for commands that accept --suggest
+	acceptsSuggest bool
+}
+
+// commandSpecs are the git commands ai-git parses, other commands are passed to git untouched
+var commandSpecs = map[string]commandSpec{
+	"commit": {
+		valueOptions: optionSet("-m", "--message", "-F", "--file", "-C", "--reuse-message", "-c", "--reedit-message",
+			"--fixup", "--squash", "--author", "--date", "-t", "--template", "--cleanup", "--trailer", "--pathspec-from-file"),
+		aiOptions:            optionSet("-m", "--message"),
+		flags:                optionSet("-a", "-p", "-s", "-n", "-e", "-v", "-q", "-o", "-i", "-z"),
+		optionalValueOptions: optionSet("-S", "-u"),
+		acceptsSplit:         true,
+	},
+	"merge": {
+		valueOptions:         optionSet("-m", "-F", "--file", "-s", "--strategy", "-X", "--strategy-option", "--cleanup", "--into-name"),
+		aiOptions:            optionSet("-m"),
+		flags:                optionSet("-n", "-e", "-q", "-v"),
+		optionalValueOptions: optionSet("-S"),
+	},
+	"stash": {
+		valueOptions:   optionSet("-m", "--message", "--pathspec-from-file"),
+		aiOptions:      optionSet("-m", "--message"),
+		flags:          optionSet("-p", "-k", "-u", "-a", "-q", "-S"),
+		acceptsExplain: true,
+	},
+	"tag": {
+		valueOptions: optionSet("-m", "--message", "-F", "--file", "-u", "--local-user", "--cleanup", "--sort", "--format",
+			"--contains", "--no-contains", "--points-at", "--merged", "--no-merged"),
+		aiOptions:            optionSet(),
+		flags:                optionSet("-a", "-s", "-f", "-d", "-v", "-l", "-i", "-e"),
+		optionalValueOptions: optionSet("-n"),
+		acceptsSuggest:       true,
+	},
+	"checkout": {
+		valueOptions:  optionSet("-b", "-B", "--orphan", "--conflict", "--pathspec-from-file"),
+		aiOptions:     optionSet("-b", "-B"),
+		createOptions: optionSet("-b", "-B"),
+		flags:         optionSet("-q", "-f", "-m", "-p", "-t", "-l"),
+		acceptsFrom:   true,
+	},
+	"switch": {
+		valueOptions:  optionSet("-c", "--create", "-C", "--force-create", "--orphan", "--conflict"),
+		aiOptions:     optionSet("-c", "--create", "-C", "--force-create"),
+		createOptions: optionSet("-c", "--create", "-C", "--force-create"),
+		flags:         optionSet("-d", "-f", "-m", "-q", "-t"),
+		acceptsFrom:   true,
+	},
+	"branch": {
+		valueOptions: optionSet("-u", "--set-upstream-to", "--contains", "--no-contains", "--merged", "--no-merged",
+			"--points-at", "--sort", "--format"),
+		aiOptions:   optionSet(),
+		flags:       optionSet("-d", "-D", "-m", "-M", "-c", "-C", "-f", "-r", "-a", "-l", "-v", "-q", "-t", "-i"),
+		acceptsFrom: true,
+	},
+}
+
+// gitArgs is a parsed invocation of a git command
+type gitArgs struct {
+	Command    string   // The git command, e.g. "commit"
+	Options    []string // Options forwarded to git, without ai-git's own flags and the valueless AI option
+	Positional []string // Positional arguments, for commit these are pathspecs
+	DoubleDash bool     // Positional arguments were separated with "--"
+	AI         bool     // AI mode was requested
+	AIOption   string   // The valueless option that requested AI mode, e.g. "-m", or "" for --ai
+	All        bool     // commit -a / --all was given
+	From       string   // Description or ticket given with --from
+	Split      bool     // commit --split was given
+	Explain    bool     // stash list --explain was given
+	Suggest    bool     // tag --suggest was given
+	Yes        bool     // --yes or --no-edit was given
+	DryRun     bool     // --dry-run was given
+	ValueGiven bool     // An AI option was given a value, e.g. commit -m "fix typo", so nothing is generated
+}
+
+// parseGitArgs parses the arguments of a git invocation. AI mode is requested with
+// --ai or with an AI option (e.g. commit -m, checkout -b) that has no value, either
+// because it is the last argument or because the next argument is another option.
+// When --ai is given, branch-creating options never take a value, so "switch --ai -c main"
+// creates a branch with a generated name starting at main. Other AI options given a
+// value, like "commit --ai -m 'fix typo'", opt out of AI mode.
+func parseGitArgs(args []string) gitArgs {
+	var parsed gitArgs
+	if len(args) == 0 {
+		return parsed
+	}
+	parsed.Command = args[0]
+
+	spec, ok := commandSpecs[parsed.Command]
+	if !ok {
+		parsed.Positional = args[1:]
+		return parsed
+	}
+
+	rest := args[1:]
+	for _, arg := range rest {
+		if arg == "--" {
+			break
+		}
+		if arg == aiFlag || (spec.acceptsSplit && arg == splitFlag) || (spec.acceptsExplain && arg == explainFlag) ||
+			(spec.acceptsSuggest && arg == suggestFlag) ||
+			(spec.acceptsFrom && (arg == fromFlag || strings.HasPrefix(arg, fromFlag+"="))) {
+			parsed.AI = true
+		}
+	}
+
+parse:
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+
+		switch {
+		case arg == "--":
+			parsed.DoubleDash = true
+			parsed.Positional = append(parsed.Positional, rest[i+1:]...)
+			break parse
+
+		case arg == aiFlag:
+			parsed.AI = true
+
+		// Without AI mode the original arguments are passed to git, so these never get lost
+		case arg == yesFlag || arg == noEditFlag:
+			parsed.Yes = true
+
+		case arg == dryRunFlag:
+			parsed.DryRun = true
+
+		case spec.acceptsSplit && arg == splitFlag:
+			parsed.AI = true
+			parsed.Split = true
+
+		case spec.acceptsExplain && arg == explainFlag:
+			parsed.AI = true
+			parsed.Explain = true
+
+		case spec.acceptsSuggest && arg == suggestFlag:
+			parsed.AI = true
+			parsed.Suggest = true
+
+		case spec.acceptsFrom && strings.HasPrefix(arg, fromFlag+"="):
+			parsed.From = strings.TrimPrefix(arg, fromFlag+"=")
+
+		case spec.acceptsFrom && arg == fromFlag:
+			if i+1 < len(rest) {
+				parsed.From = rest[i+1]
+				i++
+			}
+
+		case strings.HasPrefix(arg, "--"):
+			name, _, hasValue := strings.Cut(arg, "=")
+			if name == "--all" && parsed.Command == "commit" {
+				parsed.All = true
+			}
+			if !spec.valueOptions[name] || hasValue {
+				parsed.Options = append(parsed.Options, arg)
+				if hasValue && spec.aiOptions[name] {
+					parsed.ValueGiven = true
+				}
+				continue
+			}
+			if consumed := parsed.takeValue(spec, name, rest[i+1:]); consumed {
+				i++
+			}
+
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			if consumed := parsed.takeShortOptions(spec, arg, rest[i+1:]); consumed {
+				i++
+			}
+
+		default:
+			parsed.Positional = append(parsed.Positional, arg)
+		}
+	}
+
+	// An explicit message or name means there is nothing to generate
+	if parsed.ValueGiven && !parsed.Split && !parsed.Explain && !parsed.Suggest && parsed.From == "" {
+		parsed.AI = false
+		parsed.AIOption = ""
+	}
+	return parsed
+}
+
+// withoutAIFlag returns the arguments without --ai, for passing them on to git
+// when AI mode is not used. Arguments of commands ai-git does not parse are kept.
+func withoutAIFlag(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	if _, ok := commandSpecs[args[0]]; !ok {
+		return args
+	}
+	var result []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(result, args[i:]...)
+		}
+		if arg != aiFlag {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// HasOption reports whether the option was given, with or without an attached value
+func (parsed gitArgs) HasOption(name string) bool {
+	for _, option := range parsed.Options {
+		if option == name || strings.HasPrefix(option, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// takeShortOptions handles short options, which can be combined like "-am", "-bname"
+// or "-sSkeyid". A combination with a letter that is not a known option is forwarded
+// to git untouched. It reports whether the next argument was consumed as a value.
+func (parsed *gitArgs) takeShortOptions(spec commandSpec, arg string, next []string) bool {
+	var flags []string
+	addFlags := func() {
+		for _, name := range flags {
+			if name == "-a" && parsed.Command == "commit" {
+				parsed.All = true
+			}
+		}
+		parsed.Options = append(parsed.Options, flags...)
+	}
+
+	for j := 1; j < len(arg); j++ {
+		name, attached := "-"+arg[j:j+1], arg[j+1:]
+		switch {
+		case spec.flags[name]:
+			flags = append(flags, name)
+		case spec.optionalValueOptions[name]:
+			addFlags()
+			parsed.Options = append(parsed.Options, name+attached)
+			return false
+		case spec.valueOptions[name]:
+			addFlags()
+			if attached == "" {
+				return parsed.takeValue(spec, name, next)
+			}
+			parsed.Options = append(parsed.Options, name+attached)
+			if spec.aiOptions[name] {
+				parsed.ValueGiven = true
+			}
+			return false
+		default:
+			parsed.Options = append(parsed.Options, arg)
+			return false
+		}
+	}
+	addFlags()
+	return false
+}
+
+// takeValue handles a value option without an attached value. It reports whether
+// the next argument was consumed as the value.
+func (parsed *gitArgs) takeValue(spec commandSpec, name string, next []string) bool {
+	if spec.aiOptions[name] && ((parsed.AI && spec.createOptions[name]) || len(next) == 0 || isOption(next[0])) {
+		parsed.AI = true
+		parsed.AIOption = name
+		return false
+	}
+	if len(next) == 0 {
+		// Let git report the missing value
+		parsed.Options = append(parsed.Options, name)
+		return false
+	}
+	parsed.Options = append(parsed.Options, name, next[0])
+	if spec.aiOptions[name] {
+		parsed.ValueGiven = true
+	}
+	return true
+}
+
+// isOption reports whether the argument looks like an option rather than a value
+func isOption(arg string) bool {
+	return strings.HasPrefix(arg, "-") && arg != "-"
+}
+
+// optionSet builds a set of option names
+func optionSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGitArgs(t *testing.T) {
+	tests := []struct {
+		args       []string
+		ai         bool
+		aiOption   string
+		options    []string
+		positional []string
+	}{
+		// AI options without a value request AI mode
+		{[]string{"commit", "-m"}, true, "-m", nil, nil},
+		{[]string{"commit", "-m", "-a"}, true, "-m", []string{"-a"}, nil},
+		{[]string{"commit", "--message", "--", "a.go"}, true, "--message", nil, []string{"a.go"}},
+		{[]string{"checkout", "-b"}, true, "-b", nil, nil},
+		{[]string{"commit", "-m", "fix typo"}, false, "", []string{"-m", "fix typo"}, nil},
+		{[]string{"checkout", "-b", "feature/x"}, false, "", []string{"-b", "feature/x"}, nil},
+
+		// An explicit message opts out of AI mode, even with --ai
+		{[]string{"commit", "--ai", "-m", "fix typo"}, false, "", []string{"-m", "fix typo"}, nil},
+		{[]string{"commit", "--ai", "--message", "fix typo"}, false, "", []string{"--message", "fix typo"}, nil},
+		{[]string{"commit", "--ai", "--message=fix typo"}, false, "", []string{"--message=fix typo"}, nil},
+		{[]string{"commit", "--ai", "-mfix"}, false, "", []string{"-mfix"}, nil},
+		{[]string{"stash", "push", "--ai", "-m", "wip"}, false, "", []string{"-m", "wip"}, []string{"push"}},
+		{[]string{"stash", "push", "--ai", "--message", "wip"}, false, "", []string{"--message", "wip"}, []string{"push"}},
+		{[]string{"commit", "--ai", "-a"}, true, "", []string{"-a"}, nil},
+
+		// Combined short options are only split when every letter is known
+		{[]string{"commit", "-am"}, true, "-m", []string{"-a"}, nil},
+		{[]string{"commit", "-uno", "-m"}, true, "-m", []string{"-uno"}, nil},
+		{[]string{"commit", "-Skey", "-m"}, true, "-m", []string{"-Skey"}, nil},
+		{[]string{"commit", "-sSkey", "-m"}, true, "-m", []string{"-s", "-Skey"}, nil},
+		{[]string{"commit", "-xa", "-m"}, true, "-m", []string{"-xa"}, nil},
+		{[]string{"merge", "-Skey", "-m", "feature"}, false, "", []string{"-Skey", "-m", "feature"}, nil},
+		{[]string{"stash", "-ku", "-m"}, true, "-m", []string{"-k", "-u"}, nil},
+
+		// Branch-creating options never take a value under --ai, the next argument is the start point
+		{[]string{"switch", "--ai", "-c", "main"}, true, "-c", nil, []string{"main"}},
+		{[]string{"switch", "--ai", "--create", "main"}, true, "--create", nil, []string{"main"}},
+		{[]string{"checkout", "--ai", "-b", "main"}, true, "-b", nil, []string{"main"}},
+		{[]string{"checkout", "--ai", "-B", "main"}, true, "-B", nil, []string{"main"}},
+	}
+
+	for _, test := range tests {
+		parsed := parseGitArgs(test.args)
+		if parsed.AI != test.ai || parsed.AIOption != test.aiOption {
+			t.Errorf("parseGitArgs(%q): AI %v, AIOption %q, want %v, %q", test.args, parsed.AI, parsed.AIOption, test.ai, test.aiOption)
+		}
+		if !reflect.DeepEqual(parsed.Options, test.options) {
+			t.Errorf("parseGitArgs(%q): Options %q, want %q", test.args, parsed.Options, test.options)
+		}
+		if !reflect.DeepEqual(parsed.Positional, test.positional) {
+			t.Errorf("parseGitArgs(%q): Positional %q, want %q", test.args, parsed.Positional, test.positional)
+		}
+	}
+}
+
+func TestParseGitArgsAll(t *testing.T) {
+	tests := []struct {
+		args []string
+		all  bool
+	}{
+		{[]string{"commit", "-am"}, true},
+		{[]string{"commit", "-a", "-m"}, true},
+		{[]string{"commit", "--all", "-m"}, true},
+		{[]string{"commit", "-m"}, false},
+		// An "a" in a combination that is forwarded as is, or in a value, is not -a
+		{[]string{"commit", "-xa", "-m"}, false},
+		{[]string{"commit", "-Sabc", "-m"}, false},
+		{[]string{"commit", "-uall", "-m"}, false},
+	}
+
+	for _, test := range tests {
+		if parsed := parseGitArgs(test.args); parsed.All != test.all {
+			t.Errorf("parseGitArgs(%q): All %v, want %v", test.args, parsed.All, test.all)
+		}
+	}
+}
+
+func TestWithoutAIFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"commit", "--ai", "-m", "fix typo"}, []string{"commit", "-m", "fix typo"}},
+		{[]string{"commit", "-m", "x", "--", "--ai"}, []string{"commit", "-m", "x", "--", "--ai"}},
+		{[]string{"grep", "--ai"}, []string{"grep", "--ai"}},
+	}
+
+	for _, test := range tests {
+		if got := withoutAIFlag(test.args); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("withoutAIFlag(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestParseGitArgsUnattended(t *testing.T) {
+	tests := []struct {
+		args    []string
+		ai      bool
+		yes     bool
+		dryRun  bool
+		options []string
+	}{
+		{[]string{"commit", "-a", "-m", "--yes"}, true, true, false, []string{"-a"}},
+		{[]string{"commit", "-m", "--no-edit"}, true, true, false, nil},
+		{[]string{"commit", "-m", "--dry-run"}, true, false, true, nil},
+		{[]string{"checkout", "--dry-run", "-b"}, true, false, true, nil},
+		{[]string{"tag", "--suggest", "--yes", "--dry-run"}, true, true, true, nil},
+		// The flags alone do not request AI mode, the original arguments go to git
+		{[]string{"commit", "--dry-run"}, false, false, true, nil},
+		{[]string{"commit", "--no-edit", "--amend"}, false, true, false, []string{"--amend"}},
+		// After "--" they are pathspecs
+		{[]string{"commit", "-m", "--", "--yes"}, true, false, false, nil},
+	}
+
+	for _, test := range tests {
+		parsed := parseGitArgs(test.args)
+		if parsed.AI != test.ai || parsed.Yes != test.yes || parsed.DryRun != test.dryRun {
+			t.Errorf("parseGitArgs(%q): AI %v, Yes %v, DryRun %v, want %v, %v, %v",
+				test.args, parsed.AI, parsed.Yes, parsed.DryRun, test.ai, test.yes, test.dryRun)
+		}
+		if !reflect.DeepEqual(parsed.Options, test.options) {
+			t.Errorf("parseGitArgs(%q): Options %q, want %q", test.args, parsed.Options, test.options)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// suggestedCommand is a git command proposed by the model
+type suggestedCommand struct {
+	Args        []string `json:"args"` // The command line, starting with "git"
+	Explanation string   `json:"explanation"`
+}
+
+// askAnswer is the model's answer to a question
+type askAnswer struct {
+	Explanation string             `json:"explanation"`
+	Commands    []suggestedCommand `json:"commands"`
+}
+
+var (
+	// placeholderPattern matches placeholders like <commit> that the user has to fill in
+	placeholderPattern = regexp.MustCompile(`<[^<>\s]+>`)
+	// safeArgPattern matches arguments that need no quoting when displayed
+	safeArgPattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./~^-]+$`)
+	// configAssignmentPattern matches the value of "git -c", like core.pager=less
+	configAssignmentPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*\.[^=]*[A-Za-z0-9-]=`)
+)
+
+// newAskCmd creates the ask command
+func newAskCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "ask <question>",
+		Short: "Turn a question into git commands",
+		Long: "Ask how to do something with git in plain language, e.g. \"how do I undo the last rebase\". " +
+			"The proposed commands are explained and only run after confirmation. " +
+			"Destructive commands are flagged and need an explicit \"yes\".",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			assumeYes = yes
+			return handleAsk(loadConfig(), strings.Join(args, " "))
+		},
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "run the proposed commands without asking, unless they are destructive")
+	return cmd
+}
+
+// handleAsk proposes git commands for the question and runs them after confirmation
+func handleAsk(config ai.Config, question string) error {
+	context, err := git.GetRepoContext()
+	if err != nil {
+		return fmt.Errorf("reading the repository state: %w", err)
+	}
+	context.Exclude(git.ParseIgnoreRules(config.Policy.ExcludePaths))
+
+	// Create prompt
+	var prompt strings.Builder
+	prompt.WriteString("Answer this question about git for the repository described below: " + question + "\n\n")
+	fmt.Fprintf(&prompt, "Current branch: %s\n", context.Branch)
+	if context.Upstream != "" {
+		fmt.Fprintf(&prompt, "Upstream: %s\n", context.Upstream)
+	}
+	if context.Operation != "" {
+		fmt.Fprintf(&prompt, "In progress: %s\n", context.Operation)
+	}
+	fmt.Fprintf(&prompt, "\nStatus:\n%s\n", context.Status)
+	if context.Excluded > 0 {
+		fmt.Fprintf(&prompt, "%d file(s) not shown, excluded by the repository policy\n", context.Excluded)
+	}
+	if context.Remotes != "" {
+		fmt.Fprintf(&prompt, "\nRemotes:\n%s\n", context.Remotes)
+	}
+	if context.RecentCommits != "" {
+		fmt.Fprintf(&prompt, "\nRecent commits:\n%s\n", context.RecentCommits)
+	}
+	if context.Stashes != "" {
+		fmt.Fprintf(&prompt, "\nStashes:\n%s\n", context.Stashes)
+	}
+	prompt.WriteString("\nAnswer with a JSON object with an \"explanation\" of the solution and a list of \"commands\" to run in order, ")
+	prompt.WriteString("each an object with \"args\" (the command line as an array of arguments starting with \"git\") and a short \"explanation\". ")
+	prompt.WriteString("Only use git commands, no shell pipes or other programs. Use placeholders like <commit> for values you cannot know. ")
+	prompt.WriteString("Prefer safe commands and mention how to undo destructive ones. Just give me the JSON, no explanation needed.")
+
+	response, err := ai.GenerateGitCommands(prompt.String(), config)
+	if err != nil {
+		return &generationError{fmt.Errorf("generating answer: %w", err)}
+	}
+	answer, err := parseAskAnswer(response)
+	if err != nil {
+		return &generationError{err}
+	}
+
+	fmt.Println(answer.Explanation)
+	if len(answer.Commands) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	destructive, placeholders := false, false
+	for i, command := range answer.Commands {
+		fmt.Printf("  %d. %s\n", i+1, displayCommand(command.Args))
+		if command.Explanation != "" {
+			fmt.Printf("     %s\n", command.Explanation)
+		}
+		if warning := destructiveWarning(command.Args[1:]); warning != "" {
+			fmt.Printf("     WARNING: this %s\n", warning)
+			destructive = true
+		}
+		if placeholderPattern.MatchString(strings.Join(command.Args, " ")) {
+			placeholders = true
+		}
+	}
+	fmt.Println()
+
+	if placeholders {
+		fmt.Println("Replace the placeholders and run the commands yourself.")
+		return nil
+	}
+	if err := checkCanRun(destructive); err != nil {
+		return err
+	}
+
+	if destructive {
+		if !confirm("Type \"yes\" to run these commands, including the destructive ones: ", "yes") {
+			fmt.Println("Nothing was run.")
+			return nil
+		}
+	} else if !confirm("Run these commands? [y/N] ", "y", "yes") {
+		fmt.Println("Nothing was run.")
+		return nil
+	}
+
+	for i, command := range answer.Commands {
+		fmt.Printf("$ %s\n", displayCommand(command.Args))
+		cmd := exec.Command("git", command.Args[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("command %d failed, the remaining commands were not run: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// checkCanRun returns an error when the proposed commands cannot be confirmed: there
+// is no terminal to ask, or --yes was given for destructive commands, which always
+// need a person to type "yes"
+func checkCanRun(destructive bool) error {
+	if destructive && assumeYes {
+		return fmt.Errorf("refusing to run destructive commands with %s, run ai-git ask without it to confirm them", yesFlag)
+	}
+	return checkCanConfirm()
+}
+
+// parseAskAnswer parses the JSON answer. Commands that do not start with git are
+// rejected since only git commands are run, and so are git commands that can run
+// other programs: options before the git subcommand like "git -c", aliases and
+// git-<name> programs on the PATH.
+func parseAskAnswer(response string) (*askAnswer, error) {
+	response = trimCodeFence(response)
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not answer with commands: %s", response)
+	}
+
+	var answer askAnswer
+	if err := json.Unmarshal([]byte(response[start:end+1]), &answer); err != nil {
+		return nil, fmt.Errorf("parsing the answer: %w", err)
+	}
+
+	builtins, err := git.GetBuiltinCommands()
+	if err != nil {
+		return nil, fmt.Errorf("listing the git commands: %w", err)
+	}
+	for _, command := range answer.Commands {
+		if len(command.Args) < 2 || command.Args[0] != "git" {
+			return nil, fmt.Errorf("refusing to run %q, only git commands are supported", displayCommand(command.Args))
+		}
+		if strings.HasPrefix(command.Args[1], "-") {
+			return nil, fmt.Errorf("refusing to run %q, options before the git subcommand are not supported", displayCommand(command.Args))
+		}
+		if !builtins[command.Args[1]] {
+			return nil, fmt.Errorf("refusing to run %q, %s is not a built-in git command", displayCommand(command.Args), command.Args[1])
+		}
+		if reason := programWarning(command.Args[1:]); reason != "" {
+			return nil, fmt.Errorf("refusing to run %q, it %s", displayCommand(command.Args), reason)
+		}
+	}
+	return &answer, nil
+}
+
+// destructiveWarning describes what a git command destroys or rewrites, or returns
+// "" if it is safe. args are the arguments after "git".
+func destructiveWarning(args []string) string {
+	has := func(names ...string) bool { return hasOption(args, names...) }
+	hasShort := func(letter string) bool { return hasShortOption(args, letter) }
+	subcommand := ""
+	if len(args) > 1 {
+		subcommand = args[1]
+	}
+
+	switch args[0] {
+	case "reset":
+		if has("--hard", "--merge", "--keep") {
+			return "discards uncommitted changes"
+		}
+	case "push":
+		if has("--force", "--force-with-lease", "--force-if-includes", "--mirror") || hasShort("f") {
+			return "overwrites history on the remote"
+		}
+		if has("--delete", "--prune") || hasShort("d") {
+			return "deletes branches or tags on the remote"
+		}
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "+") {
+				return "overwrites history on the remote"
+			}
+			if strings.HasPrefix(arg, ":") {
+				return "deletes branches or tags on the remote"
+			}
+		}
+	case "clean":
+		if has("--force") || hasShort("f") {
+			return "permanently deletes untracked files"
+		}
+	case "branch":
+		if hasShort("D") || (has("--delete", "-d") && has("--force", "-f")) {
+			return "deletes a branch even if it is not merged"
+		}
+		if hasShort("M") || hasShort("C") {
+			return "overwrites an existing branch"
+		}
+		if has("--force") || hasShort("f") {
+			return "resets an existing branch"
+		}
+	case "checkout", "switch":
+		if has("--force", "--discard-changes") || hasShort("f") {
+			return "discards uncommitted changes"
+		}
+		if args[0] == "checkout" && (has("--", "--pathspec-from-file") || len(checkoutPaths(args[1:])) > 0) {
+			return "overwrites uncommitted changes in the given files"
+		}
+		if hasShort("B") || hasShort("C") {
+			return "resets an existing branch"
+		}
+	case "restore":
+		if !has("--staged") && !hasShort("S") || has("--worktree") || hasShort("W") {
+			return "discards uncommitted changes in the working tree"
+		}
+	case "stash":
+		if subcommand == "drop" || subcommand == "clear" {
+			return "permanently deletes stashed changes"
+		}
+	case "rebase":
+		if !has("--abort", "--continue", "--quit", "--edit-todo", "--show-current-patch") {
+			return "rewrites commit history"
+		}
+	case "filter-branch", "filter-repo":
+		return "rewrites commit history"
+	case "commit":
+		if has("--amend") {
+			return "rewrites the last commit"
+		}
+	case "reflog":
+		if subcommand == "expire" || subcommand == "delete" {
+			return "removes the history used to recover lost commits"
+		}
+	case "gc", "prune":
+		if has("--prune", "--expire") || args[0] == "prune" {
+			return "permanently deletes unreachable commits"
+		}
+	case "update-ref":
+		if hasShort("d") {
+			return "deletes a ref"
+		}
+		return "overwrites a ref"
+	case "tag":
+		if has("--delete") || hasShort("d") {
+			return "deletes a tag"
+		}
+		if has("--force") || hasShort("f") {
+			return "overwrites an existing tag"
+		}
+	case "rm":
+		if !has("--cached") {
+			return "deletes files from the working tree"
+		}
+	case "worktree":
+		if subcommand == "remove" && (has("--force") || hasShort("f")) {
+			return "deletes a worktree with uncommitted changes"
+		}
+	}
+	return ""
+}
+
+// programWarning describes how a git command can run programs other than git, or
+// returns "" if it cannot. args are the arguments after "git".
+func programWarning(args []string) string {
+	has := func(names ...string) bool { return hasOption(args, names...) }
+	hasShort := func(letter string) bool { return hasShortOption(args, letter) }
+	subcommand := ""
+	if len(args) > 1 {
+		subcommand = args[1]
+	}
+
+	// Global options are refused before the subcommand, but the model may put them anywhere
+	if has("--config-env", "--exec-path") {
+		return "changes git's configuration or programs"
+	}
+	for i, arg := range args {
+		if (arg == "-c" && i+1 < len(args) && configAssignmentPattern.MatchString(args[i+1])) ||
+			(strings.HasPrefix(arg, "-c") && configAssignmentPattern.MatchString(arg[2:])) {
+			return "changes git's configuration, which can define commands like core.sshCommand"
+		}
+	}
+	switch args[0] {
+	case "config":
+		if !configReadOnly(args[1:]) {
+			return "changes the configuration, which can define aliases and commands like core.sshCommand"
+		}
+	case "submodule":
+		if subcommand == "foreach" {
+			return "runs a shell command in each submodule"
+		}
+	case "bisect":
+		if subcommand == "run" {
+			return "runs a command to test each commit"
+		}
+	case "difftool":
+		if has("--extcmd") || hasShort("x") {
+			return "runs the given command to show diffs"
+		}
+	case "rebase":
+		if has("--exec") || hasShort("x") {
+			return "runs a shell command after each commit"
+		}
+	case "filter-branch":
+		return "runs shell commands to rewrite commits"
+	case "grep":
+		if has("--open-files-in-pager") || hasShort("O") {
+			return "opens the matches in another program"
+		}
+	case "fetch", "pull", "clone", "ls-remote", "push", "archive":
+		if has("--upload-pack", "--receive-pack", "--exec") || (args[0] == "clone" && hasShort("u")) {
+			return "runs the given program on the remote side"
+		}
+	}
+	return ""
+}
+
+// configReadOnly reports whether git config with these arguments only reads the
+// configuration: a listing or lookup option, or a single key
+func configReadOnly(args []string) bool {
+	var keys []string
+	for _, arg := range args {
+		switch {
+		case arg == "-l" || hasOption([]string{arg}, "--list", "--get", "--get-all", "--get-regexp", "--get-urlmatch", "--get-color", "--get-colorbool"):
+			return true
+		case strings.HasPrefix(arg, "-"):
+			// Scope and type options like --global or --bool do not write by themselves
+			if hasOption([]string{arg}, "--global", "--system", "--local", "--worktree", "--show-origin", "--show-scope",
+				"--type", "--bool", "--int", "--bool-or-int", "--path", "--expiry-date", "--null", "-z", "--includes", "--no-includes") {
+				continue
+			}
+			return false
+		default:
+			keys = append(keys, arg)
+		}
+	}
+	return len(keys) == 1
+}
+
+// checkoutPaths returns the paths given to git checkout without "--": every argument
+// after a tree-ish, or a single argument that is not a revision but a file or ".".
+// args are the arguments after "checkout".
+func checkoutPaths(args []string) []string {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-b" || arg == "-B" || arg == "--orphan":
+			// Creating a branch, the next arguments are the name and the start point
+			return nil
+		case strings.HasPrefix(arg, "-"):
+			continue
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) > 1 {
+		return positional[1:]
+	}
+	if len(positional) == 1 {
+		arg := positional[0]
+		if arg == "." || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, ":") {
+			return positional
+		}
+		if _, err := os.Lstat(arg); err == nil && !git.RevisionExists(arg) {
+			return positional
+		}
+	}
+	return nil
+}
+
+// hasOption reports whether one of the options is given, with or without an attached value
+func hasOption(args []string, names ...string) bool {
+	for _, arg := range args {
+		for _, name := range names {
+			if arg == name || strings.HasPrefix(arg, name+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasShortOption reports whether a short option is given, also combined like "-fdx"
+func hasShortOption(args []string, letter string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg[1:], letter) {
+			return true
+		}
+	}
+	return false
+}
+
+// displayCommand formats a command line for display, quoting arguments as needed
+func displayCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if safeArgPattern.MatchString(arg) || placeholderPattern.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
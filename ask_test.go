@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDestructiveWarning(t *testing.T) {
+	tests := []struct {
+		command     string
+		destructive bool
+	}{
+		{"status", false},
+		{"reset --soft HEAD~1", false},
+		{"reset --hard HEAD~1", true},
+		{"push origin main", false},
+		{"push --force origin main", true},
+		{"push -fu origin main", true},
+		{"push origin +main", true},
+		{"push origin :old", true},
+		{"clean -n", false},
+		{"clean -fdx", true},
+		{"branch -d feature", false},
+		{"branch -D feature", true},
+		{"branch feature main", false},
+		{"branch -f feature main", true},
+		{"branch --force feature main", true},
+		{"branch -C feature copy", true},
+		{"checkout feature", false},
+		{"checkout -b feature main", false},
+		{"checkout -B feature", true},
+		{"checkout -- main.go", true},
+		{"checkout HEAD main.go", true},
+		{"checkout .", true},
+		{"checkout main.go", true},
+		{"switch feature", false},
+		{"switch --discard-changes feature", true},
+		{"restore --staged main.go", false},
+		{"restore main.go", true},
+		{"stash list", false},
+		{"stash drop", true},
+		{"rebase main", true},
+		{"rebase --continue", false},
+		{"commit --amend", true},
+		{"tag -d v1.0.0", true},
+		{"update-ref -d refs/heads/old", true},
+		{"update-ref refs/heads/main 1a2b3c4", true},
+		{"rm --cached secret.txt", false},
+		{"rm secret.txt", true},
+	}
+
+	for _, test := range tests {
+		warning := destructiveWarning(strings.Fields(test.command))
+		if (warning != "") != test.destructive {
+			t.Errorf("destructiveWarning(%q) = %q, want destructive %v", test.command, warning, test.destructive)
+		}
+	}
+}
+
+func TestProgramWarning(t *testing.T) {
+	tests := []struct {
+		command string
+		runs    bool
+	}{
+		{"log --oneline", false},
+		{"config user.email", false},
+		{"config --get core.editor", false},
+		{"config --global --list", false},
+		{"config alias.st status", true},
+		{"config --global core.sshCommand ssh", true},
+		{"config --unset alias.st", true},
+		{"config -e", true},
+		{"submodule update --init", false},
+		{"submodule foreach make", true},
+		{"bisect start", false},
+		{"bisect run make test", true},
+		{"difftool --tool=vimdiff", false},
+		{"difftool -x cat", true},
+		{"difftool --extcmd=cat", true},
+		{"rebase -i main", false},
+		{"rebase --exec make main", true},
+		{"rebase -x make main", true},
+		{"switch -c feature", false},
+		{"commit -c HEAD", false},
+		{"status -c core.fsmonitor=evil", true},
+		{"status -ccore.pager=evil", true},
+		{"status --exec-path=/tmp", true},
+		{"fetch --upload-pack=evil origin", true},
+		{"grep -O todo", true},
+		{"filter-branch --tree-filter rm", true},
+	}
+
+	for _, test := range tests {
+		warning := programWarning(strings.Fields(test.command))
+		if (warning != "") != test.runs {
+			t.Errorf("programWarning(%q) = %q, want running programs %v", test.command, warning, test.runs)
+		}
+	}
+}
+
+func TestParseAskAnswer(t *testing.T) {
+	tests := []struct {
+		response string
+		valid    bool
+	}{
+		{`{"explanation": "x", "commands": [{"args": ["git", "status"]}]}`, true},
+		{"```json\n{\"explanation\": \"x\", \"commands\": []}\n```", true},
+		{`{"explanation": "x", "commands": [{"args": ["rm", "-rf", "/"]}]}`, false},
+		{`{"explanation": "x", "commands": [{"args": ["git", "-c", "alias.x=!sh", "x"]}]}`, false},
+		{`{"explanation": "x", "commands": [{"args": ["git", "config", "alias.x", "!sh"]}]}`, false},
+		{`{"explanation": "x", "commands": [{"args": ["git", "bisect", "run", "sh"]}]}`, false},
+		{`{"explanation": "x", "commands": [{"args": ["git", "st"]}]}`, false},
+		{`{"explanation": "x", "commands": [{"args": ["git", "status"]}, {"args": ["git", "lfs", "pull"]}]}`, false},
+		{"no commands", false},
+	}
+
+	for _, test := range tests {
+		_, err := parseAskAnswer(test.response)
+		if (err == nil) != test.valid {
+			t.Errorf("parseAskAnswer(%q) = %v, want valid %v", test.response, err, test.valid)
+		}
+	}
+}
+
+func TestCheckCanRun(t *testing.T) {
+	defer func() { assumeYes = false }()
+	assumeYes = true
+
+	if err := checkCanRun(false); err != nil {
+		t.Errorf("checkCanRun(false) with --yes = %v, want nil", err)
+	}
+	if err := checkCanRun(true); err == nil {
+		t.Error("checkCanRun(true) with --yes = nil, want an error")
+	}
+}
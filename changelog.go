@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// changelogSections are the Keep a Changelog sections in the order they are written
+var changelogSections = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+
+// conventionalSections maps Conventional Commits types to changelog sections.
+// Types that are not listed, like docs, chore or test, are left out unless breaking.
+var conventionalSections = map[string]string{
+	"feat":      "Added",
+	"fix":       "Fixed",
+	"perf":      "Changed",
+	"refactor":  "Changed",
+	"revert":    "Changed",
+	"deprecate": "Deprecated",
+	"remove":    "Removed",
+	"security":  "Security",
+}
+
+// changelogHeader starts a new CHANGELOG.md
+const changelogHeader = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/).
+
+`
+
+// changelogEntry is a single line of the changelog
+type changelogEntry struct {
+	Section  string
+	Text     string
+	Breaking bool
+	Refs     []string
+}
+
+// newChangelogCmd creates the changelog command
+func newChangelogCmd() *cobra.Command {
+	var prepend bool
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "changelog <from>..<to>",
+		Short: "Generate changelog entries for a range of commits",
+		Long: "Generate Keep a Changelog style Markdown for the commits in from..to (to defaults to HEAD). " +
+			"Conventional commits are grouped by their type, other commits are classified by the AI model. " +
+			"Breaking changes are highlighted and pull request and issue numbers are included. " +
+			"The entries are headed by the version and date of to if it is a tag, and by Unreleased otherwise.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleChangelog(args[0], prepend, file)
+		},
+	}
+	cmd.Flags().BoolVar(&prepend, "prepend", false, "insert the entries into the changelog file instead of printing them")
+	cmd.Flags().StringVar(&file, "file", "CHANGELOG.md", "the changelog file updated by --prepend")
+	return cmd
+}
+
+// changelogHeading returns the heading of the release ending at to: the version and
+// date of a tag, or Unreleased for any other revision, like HEAD or a branch
+func changelogHeading(to string) (string, error) {
+	if !git.TagExists(to) {
+		return "## [Unreleased]", nil
+	}
+	date, err := git.GetCommitDate(to)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("## [%s] - %s", strings.TrimPrefix(to, "v"), date), nil
+}
+
+// handleChangelog generates the changelog for a commit range
+func handleChangelog(revRange string, prepend bool, file string) error {
+	from, to, found := strings.Cut(revRange, "..")
+	if !found || from == "" {
+		return fmt.Errorf("expected a range like v1.0.0..v1.1.0, got %q", revRange)
+	}
+	to = strings.TrimPrefix(to, ".")
+	if to == "" {
+		to = "HEAD"
+	}
+
+	commits, err := git.GetCommits("--no-merges", from+".."+to)
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits between %s and %s", from, to)
+	}
+
+	entries, err := classifyCommits(commits)
+	if err != nil {
+		return err
+	}
+
+	heading, err := changelogHeading(to)
+	if err != nil {
+		return err
+	}
+	release := formatChangelog(heading, entries)
+
+	if !prepend {
+		fmt.Print(release)
+		return nil
+	}
+	if err := prependChangelog(file, release); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Updated %s with %d commit(s)\n", file, len(commits))
+	return nil
+}
+
+// classifyCommits turns commits into changelog entries. Conventional commits are
+// classified by type, the others are sent to the AI model in a single request.
+func classifyCommits(commits []git.Commit) ([]changelogEntry, error) {
+	var entries []changelogEntry
+	var others []git.Commit
+
+	for _, commit := range commits {
+		refs := git.ParseReferences(commit.Message())
+		conventional, ok := git.ParseConventionalCommit(commit.Subject, commit.Body)
+		if !ok {
+			others = append(others, commit)
+			continue
+		}
+
+		section, known := conventionalSections[conventional.Type]
+		if !known {
+			if !conventional.Breaking {
+				continue
+			}
+			section = "Changed"
+		}
+
+		text := git.StripReferences(conventional.Description)
+		if conventional.Scope != "" {
+			text = "**" + conventional.Scope + ":** " + text
+		}
+		if conventional.BreakingNote != "" {
+			text += ": " + conventional.BreakingNote
+		}
+		entries = append(entries, changelogEntry{Section: section, Text: text, Breaking: conventional.Breaking, Refs: refs})
+	}
+
+	if len(others) == 0 {
+		return entries, nil
+	}
+
+	aiEntries, err := classifyCommitsWithAI(loadConfig(), others)
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, aiEntries...), nil
+}
+
+// classifyCommitsWithAI asks the AI model for a section and an entry per commit
+func classifyCommitsWithAI(config ai.Config, commits []git.Commit) ([]changelogEntry, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Classify each of these commits for a changelog.\n\n")
+	for i, commit := range commits {
+		fmt.Fprintf(&prompt, "Commit %d:\n%s\n\n", i+1, commit.Message())
+	}
+	fmt.Fprintf(&prompt, "Answer with one line per commit in the form \"<number> | <section> | <breaking> | <entry>\" where section is one of %s, "+
+		"or Skip for changes that do not matter to users like tests, formatting or CI; breaking is yes or no; "+
+		"and entry is a short description for users in the imperative mood without issue numbers.\n", strings.Join(changelogSections, ", "))
+	prompt.WriteString("Just give me the lines, no explanation needed.")
+
+	fmt.Fprintf(os.Stderr, "Classifying %d commit(s) without a conventional type...\n", len(commits))
+	answer, err := ai.GenerateChangelogEntries(prompt.String(), config)
+	if err != nil {
+		return nil, &generationError{fmt.Errorf("classifying commits: %w", err)}
+	}
+
+	// Commits the model did not answer for keep their subject under Changed
+	classified := make(map[int]changelogEntry)
+	for _, line := range strings.Split(answer, "\n") {
+		fields := strings.Split(strings.Trim(strings.TrimSpace(line), "`-* "), "|")
+		if len(fields) != 4 {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil || number < 1 || number > len(commits) {
+			continue
+		}
+		classified[number-1] = changelogEntry{
+			Section:  normalizeSection(fields[1]),
+			Breaking: strings.EqualFold(strings.TrimSpace(fields[2]), "yes"),
+			Text:     git.StripReferences(strings.TrimSpace(fields[3])),
+		}
+	}
+
+	var entries []changelogEntry
+	for i, commit := range commits {
+		entry, ok := classified[i]
+		if !ok {
+			entry = changelogEntry{Section: "Changed", Text: git.StripReferences(commit.Subject)}
+		}
+		if entry.Section == "" || entry.Text == "" {
+			continue
+		}
+		entry.Refs = git.ParseReferences(commit.Message())
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// normalizeSection returns the changelog section matching the model's answer,
+// "" to skip the commit, or Changed if the answer is not a known section
+func normalizeSection(answer string) string {
+	answer = strings.TrimSpace(answer)
+	if strings.EqualFold(answer, "skip") {
+		return ""
+	}
+	for _, section := range changelogSections {
+		if strings.EqualFold(answer, section) {
+			return section
+		}
+	}
+	return "Changed"
+}
+
+// formatChangelog renders a release with its entries grouped by section.
+// Breaking changes come first within their section.
+func formatChangelog(heading string, entries []changelogEntry) string {
+	var sb strings.Builder
+	sb.WriteString(heading + "\n")
+
+	for _, section := range changelogSections {
+		var lines []string
+		for _, breaking := range []bool{true, false} {
+			for _, entry := range entries {
+				if entry.Section != section || entry.Breaking != breaking {
+					continue
+				}
+				line := "- "
+				if entry.Breaking {
+					line += "**BREAKING:** "
+				}
+				line += entry.Text
+				if len(entry.Refs) > 0 {
+					line += " (" + strings.Join(entry.Refs, ", ") + ")"
+				}
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			sb.WriteString("\n### " + section + "\n\n")
+			sb.WriteString(strings.Join(lines, "\n") + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// prependChangelog inserts the release above the newest release in the changelog file,
+// and creates a missing file. A tagged release goes below an existing Unreleased section,
+// which is left for the user to clean up. A new Unreleased section is merged into the
+// existing one, so entries written by hand are kept.
+func prependChangelog(file, release string) error {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return os.WriteFile(file, []byte(changelogHeader+release), 0o644)
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	start, end := len(lines), len(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "## ") {
+			start, end = i, i
+			if isUnreleasedHeading(line) {
+				end = i + 1
+				for end < len(lines) && !strings.HasPrefix(lines[end], "## ") {
+					end++
+				}
+			}
+			break
+		}
+	}
+
+	if end > start {
+		if isUnreleasedHeading(release) {
+			release = mergeChangelogSection(lines[start:end], release)
+		} else {
+			fmt.Fprintf(os.Stderr, "Kept the Unreleased section of %s, move or remove its entries that are part of this release\n", file)
+			start = end
+		}
+	}
+
+	var sb strings.Builder
+	before := strings.TrimRight(strings.Join(lines[:start], "\n"), "\n")
+	if before != "" {
+		sb.WriteString(before + "\n\n")
+	}
+	sb.WriteString(release)
+	if after := strings.Join(lines[end:], "\n"); strings.TrimSpace(after) != "" {
+		sb.WriteString("\n" + after)
+	}
+	return os.WriteFile(file, []byte(sb.String()), 0o644)
+}
+
+// isUnreleasedHeading reports whether the text starts with the Unreleased heading
+func isUnreleasedHeading(text string) bool {
+	return strings.HasPrefix(strings.ToLower(text), "## [unreleased]")
+}
+
+// changelogSection is a "###" section of a release, or the text before the first one
+type changelogSection struct {
+	name  string
+	lines []string
+}
+
+// mergeChangelogSection adds the lines of an existing release section that the new
+// release does not have, under the same "###" heading
+func mergeChangelogSection(existing []string, release string) string {
+	releaseLines := strings.Split(strings.TrimRight(release, "\n"), "\n")
+	merged := parseChangelogSections(releaseLines[1:])
+	for _, old := range parseChangelogSections(existing[1:]) {
+		var target *changelogSection
+		for _, section := range merged {
+			if section.name == old.name {
+				target = section
+			}
+		}
+		if target == nil {
+			target = &changelogSection{name: old.name}
+			merged = append(merged, target)
+		}
+		for _, line := range old.lines {
+			if !slices.Contains(target.lines, line) {
+				target.lines = append(target.lines, line)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(releaseLines[0] + "\n")
+	for _, section := range merged {
+		if len(section.lines) == 0 {
+			continue
+		}
+		if section.name != "" {
+			sb.WriteString("\n### " + section.name + "\n")
+		}
+		sb.WriteString("\n" + strings.Join(section.lines, "\n") + "\n")
+	}
+	return sb.String()
+}
+
+// parseChangelogSections splits the lines of a release below its heading into sections,
+// dropping blank lines
+func parseChangelogSections(lines []string) []*changelogSection {
+	sections := []*changelogSection{{}}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "### ") {
+			sections = append(sections, &changelogSection{name: strings.TrimSpace(line[4:])})
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			current := sections[len(sections)-1]
+			current.lines = append(current.lines, line)
+		}
+	}
+	return sections
+}
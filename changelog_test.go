@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrependChangelog(t *testing.T) {
+	existing := "# Changelog\n\n## [Unreleased]\n\n### Added\n\n- Hand-written entry\n\n## [1.0.0] - 2024-01-01\n\n### Added\n\n- First release\n"
+
+	tests := []struct {
+		name     string
+		existing string
+		release  string
+		want     string
+	}{
+		{
+			name:     "new file",
+			existing: "",
+			release:  "## [1.1.0] - 2024-02-01\n\n### Fixed\n\n- Fix crash\n",
+			want:     changelogHeader + "## [1.1.0] - 2024-02-01\n\n### Fixed\n\n- Fix crash\n",
+		},
+		{
+			name:     "tagged release below unreleased",
+			existing: existing,
+			release:  "## [1.1.0] - 2024-02-01\n\n### Fixed\n\n- Fix crash\n",
+			want: "# Changelog\n\n## [Unreleased]\n\n### Added\n\n- Hand-written entry\n\n" +
+				"## [1.1.0] - 2024-02-01\n\n### Fixed\n\n- Fix crash\n\n" +
+				"## [1.0.0] - 2024-01-01\n\n### Added\n\n- First release\n",
+		},
+		{
+			name:     "unreleased merged",
+			existing: existing,
+			release:  "## [Unreleased]\n\n### Added\n\n- Generated entry\n\n### Fixed\n\n- Fix crash\n",
+			want: "# Changelog\n\n## [Unreleased]\n\n### Added\n\n- Generated entry\n- Hand-written entry\n\n### Fixed\n\n- Fix crash\n\n" +
+				"## [1.0.0] - 2024-01-01\n\n### Added\n\n- First release\n",
+		},
+		{
+			name:     "no unreleased",
+			existing: "# Changelog\n\n## [1.0.0] - 2024-01-01\n\n- First release\n",
+			release:  "## [1.1.0] - 2024-02-01\n\n### Fixed\n\n- Fix crash\n",
+			want:     "# Changelog\n\n## [1.1.0] - 2024-02-01\n\n### Fixed\n\n- Fix crash\n\n## [1.0.0] - 2024-01-01\n\n- First release\n",
+		},
+	}
+
+	for _, test := range tests {
+		file := filepath.Join(t.TempDir(), "CHANGELOG.md")
+		if test.existing != "" {
+			if err := os.WriteFile(file, []byte(test.existing), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := prependChangelog(file, test.release); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestChangelogHeading(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_COMMITTER_DATE", "2024-02-01T12:00:00Z")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "base"},
+		{"tag", "v1.1.0"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	tests := []struct {
+		to   string
+		want string
+	}{
+		{"v1.1.0", "## [1.1.0] - 2024-02-01"},
+		{"HEAD", "## [Unreleased]"},
+		{"main", "## [Unreleased]"},
+	}
+
+	for _, test := range tests {
+		heading, err := changelogHeading(test.to)
+		if err != nil {
+			t.Fatalf("%s: %v", test.to, err)
+		}
+		if heading != test.want {
+			t.Errorf("changelogHeading(%q) = %q, want %q", test.to, heading, test.want)
+		}
+	}
+}
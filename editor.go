@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// assumeYes is set by --yes and --no-edit: generated text is used without opening
+// the editor and confirmations are answered with yes
+var assumeYes bool
+
+// canEdit reports whether the editor can be opened: --yes was not given and ai-git
+// runs in a terminal, not in CI, a git GUI or with redirected input
+func canEdit() bool {
+	return !assumeYes && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// The null device is a character device too, but nothing can be typed on it
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// editText writes the text and the comment to a temporary file, opens it in the
+// user's editor and returns the edited text with comment lines removed.
+// pattern is the name pattern of the temporary file, as for os.CreateTemp.
+// Without an editor (see canEdit) the text is returned as if saved unchanged.
+func editText(text, comment, pattern string) (string, error) {
+	if !canEdit() {
+		return stripComments(text), nil
+	}
+
+	// Write the text to a temporary file for editing
+	tempFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name()) // Clean up file when done
+
+	fmt.Fprintf(tempFile, "%s\n\n%s", text, comment)
+	tempFile.Close()
+
+	edited, err := runEditor(tempFile.Name())
+	if err != nil {
+		return "", err
+	}
+	return stripComments(edited), nil
+}
+
+// editCode opens the text in the user's editor and returns it unchanged apart from
+// a trailing newline. Unlike editText, lines starting with # are kept, since they
+// may be code. pattern is the name pattern of the temporary file.
+func editCode(text, pattern string) (string, error) {
+	tempFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name()) // Clean up file when done
+
+	fmt.Fprintf(tempFile, "%s\n", text)
+	tempFile.Close()
+
+	edited, err := runEditor(tempFile.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(edited, "\n"), nil
+}
+
+// runEditor opens the file in the user's editor and returns its content afterwards
+func runEditor(path string) (string, error) {
+	editCmd := exec.Command(editorCommand(), path)
+	editCmd.Stdin = os.Stdin
+	editCmd.Stdout = os.Stdout
+	editCmd.Stderr = os.Stderr
+	if err := editCmd.Run(); err != nil {
+		return "", fmt.Errorf("opening editor: %w", err)
+	}
+
+	// Read the edited text
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading edited text: %w", err)
+	}
+	return string(edited), nil
+}
+
+// editorCommand returns the user's editor
+func editorCommand() string {
+	editor := os.Getenv("AI_GIT_EDITOR")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+		if editor == "" {
+			editor = "vim" // Default to vim if no editor is set
+		}
+	}
+	return editor
+}
+
+// stripComments removes lines starting with # and surrounding whitespace
+func stripComments(text string) string {
+	lines := strings.Split(text, "\n")
+	var finalLines []string
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			finalLines = append(finalLines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(finalLines, "\n"))
+}
+
+// stdin is shared by all prompts so that input read ahead by one is not lost
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptLine asks a question on stderr and returns the line the user typed,
+// or "" at the end of the input
+func promptLine(question string) string {
+	fmt.Fprint(os.Stderr, question)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+	}
+	return strings.TrimSpace(line)
+}
+
+// checkCanConfirm returns an error when a confirmation is needed but cannot be asked,
+// because stdin is not a terminal and --yes was not given. Reading the answer would
+// only hit the end of the input and silently cancel.
+func checkCanConfirm() error {
+	if assumeYes || isTerminal(os.Stdin) {
+		return nil
+	}
+	return fmt.Errorf("cannot ask for confirmation, stdin is not a terminal; use %s to proceed without asking", yesFlag)
+}
+
+// confirm asks a question on stderr and reports whether the user typed one of
+// the accepted answers, ignoring case. With --yes it answers yes itself.
+func confirm(question string, accepted ...string) bool {
+	if assumeYes {
+		fmt.Fprintln(os.Stderr, question+"yes")
+		return true
+	}
+	answer := promptLine(question)
+	for _, a := range accepted {
+		if strings.EqualFold(answer, a) {
+			return true
+		}
+	}
+	return false
+}
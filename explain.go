@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// explainFormats are the instructions for each output format of the explain command
+var explainFormats = map[string]string{
+	"short":    "Explain in two or three sentences of plain text what changed and why.",
+	"detailed": "Explain in plain text, without Markdown, what changed and why, how the changes work, and what they affect. Use short paragraphs.",
+	"markdown": "Explain in Markdown with the sections \"## Summary\", \"## What changed\" (a bullet list), \"## Why\" and \"## Impact\".",
+}
+
+// newExplainCmd creates the explain command
+func newExplainCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "explain <rev|rev-range>",
+		Short: "Explain a commit or a range of commits in plain language",
+		Long: "Explain what a commit, or the commits in a range like main..feature, changed and why, " +
+			"based on the commit messages and the diff.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := explainFormats[format]; !ok {
+				return fmt.Errorf("unknown format %q, expected short, detailed or markdown", format)
+			}
+			return handleExplain(loadConfig(), args[0], format)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "short", "output format: short, detailed or markdown")
+	return cmd
+}
+
+// handleExplain explains a revision or a revision range
+func handleExplain(config ai.Config, rev, format string) error {
+	var commits []git.Commit
+	var changes *git.Changes
+	var err error
+
+	if from, to, isRange := strings.Cut(rev, ".."); isRange {
+		to = strings.TrimPrefix(to, ".")
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		commits, err = git.GetCommits(from + ".." + to)
+		if err != nil {
+			return fmt.Errorf("listing commits: %w", err)
+		}
+		if len(commits) == 0 {
+			return fmt.Errorf("no commits in %s", rev)
+		}
+		// The combined diff of the range is the one since the fork point
+		mergeBase, err := git.GetMergeBase(from, to)
+		if err != nil {
+			return fmt.Errorf("finding the merge base of %s and %s: %w", from, to, err)
+		}
+		changes, err = git.GetDiffChanges(mergeBase, to)
+		if err != nil {
+			return fmt.Errorf("getting git changes: %w", err)
+		}
+	} else {
+		if !git.RevisionExists(rev) {
+			return fmt.Errorf("unknown revision %s", rev)
+		}
+		commits, err = git.GetCommits("-1", rev)
+		if err != nil {
+			return fmt.Errorf("reading commit %s: %w", rev, err)
+		}
+		if len(commits) == 0 {
+			return fmt.Errorf("no commit found for %s", rev)
+		}
+		// Merge commits are compared with their first parent
+		parent := rev + "^"
+		if !git.RevisionExists(parent) {
+			parent = git.EmptyTree
+		}
+		changes, err = git.GetDiffChanges(parent, rev)
+		if err != nil {
+			return fmt.Errorf("getting git changes: %w", err)
+		}
+	}
+
+	// Format changes for the prompt
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		return &generationError{fmt.Errorf("summarizing git changes: %w", err)}
+	}
+
+	// Create prompt
+	var prompt strings.Builder
+	if len(commits) == 1 {
+		prompt.WriteString("Explain this commit to a developer who does not know the code base.\n\n")
+	} else {
+		fmt.Fprintf(&prompt, "Explain these %d commits as a whole to a developer who does not know the code base.\n\n", len(commits))
+	}
+	for _, commit := range commits {
+		fmt.Fprintf(&prompt, "Commit %s by %s on %s:\n%s\n\n", commit.ShortHash(), commit.Author, commit.Date, commit.Message())
+	}
+	prompt.WriteString(formattedChanges + "\n")
+	prompt.WriteString(explainFormats[format])
+	prompt.WriteString(" If the reason for a change is not clear from the messages and the diff, say so instead of guessing.")
+
+	fmt.Fprintf(os.Stderr, "Explaining %d commit(s)...\n", len(commits))
+	explanation, err := ai.GenerateExplanation(prompt.String(), config)
+	if err != nil {
+		return &generationError{fmt.Errorf("generating explanation: %w", err)}
+	}
+	fmt.Println(trimCodeFence(explanation))
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+)
+
+const (
+	// hookName is the git hook ai-git installs
+	hookName = "prepare-commit-msg"
+	// hookMarker identifies a hook script installed by ai-git
+	hookMarker = "# Installed by ai-git hook install"
+	// chainedHookSuffix is appended to an existing hook that ai-git runs before its own
+	chainedHookSuffix = ".ai-git-chained"
+)
+
+// hookScript is the prepare-commit-msg hook. It runs a previously installed hook
+// first and then fills the commit message with ai-git.
+const hookScript = `#!/bin/sh
+` + hookMarker + `, remove it with "ai-git hook uninstall".
+chained="$(dirname "$0")/` + hookName + chainedHookSuffix + `"
+if [ -x "$chained" ]; then
+	"$chained" "$@" || exit $?
+fi
+exec %s hook ` + hookName + ` "$@"
+`
+
+// isHookCommand reports whether ai-git handles the git hook subcommand itself.
+// Other subcommands like "git hook run" are passed to git.
+func isHookCommand(args []string) bool {
+	if len(args) < 2 || args[0] != "hook" {
+		return false
+	}
+	switch args[1] {
+	case "install", "uninstall", hookName:
+		return true
+	}
+	return false
+}
+
+// handleHook runs ai-git hook install, uninstall or the hook itself
+func handleHook(args []string) {
+	switch args[0] {
+	case "install":
+		if err := installHook(); err != nil {
+			log.Fatalf("Error installing %s hook: %v", hookName, err)
+		}
+	case "uninstall":
+		if err := uninstallHook(); err != nil {
+			log.Fatalf("Error uninstalling %s hook: %v", hookName, err)
+		}
+	case hookName:
+		runPrepareCommitMsgHook(args[1:])
+	}
+}
+
+// installHook installs the prepare-commit-msg hook. An existing hook that was not
+// installed by ai-git is kept and run before ai-git.
+func installHook() error {
+	hooksDir, err := git.GetHooksDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return err
+	}
+
+	hookPath := filepath.Join(hooksDir, hookName)
+	chainedPath := hookPath + chainedHookSuffix
+
+	if existing, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(existing), hookMarker) {
+		if _, err := os.Stat(chainedPath); err == nil {
+			return fmt.Errorf("both %s and %s exist, remove one of them first", hookPath, chainedPath)
+		}
+		if err := os.Rename(hookPath, chainedPath); err != nil {
+			return err
+		}
+		fmt.Printf("Existing hook moved to %s, it will run before ai-git\n", chainedPath)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "ai-git"
+	}
+
+	script := fmt.Sprintf(hookScript, shellQuote(executable))
+	if err := os.WriteFile(hookPath, []byte(script), 0o755); err != nil {
+		return err
+	}
+
+	fmt.Printf("Installed %s hook in %s\n", hookName, hookPath)
+	return nil
+}
+
+// uninstallHook removes the prepare-commit-msg hook and restores a chained hook
+func uninstallHook() error {
+	hooksDir, err := git.GetHooksDir()
+	if err != nil {
+		return err
+	}
+
+	hookPath := filepath.Join(hooksDir, hookName)
+	chainedPath := hookPath + chainedHookSuffix
+
+	existing, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no %s hook is installed in %s", hookName, hooksDir)
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(existing), hookMarker) {
+		return fmt.Errorf("%s was not installed by ai-git, leaving it untouched", hookPath)
+	}
+
+	if err := os.Remove(hookPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(chainedPath); err == nil {
+		if err := os.Rename(chainedPath, hookPath); err != nil {
+			return err
+		}
+		fmt.Printf("Restored previous hook %s\n", hookPath)
+	}
+
+	fmt.Printf("Uninstalled %s hook from %s\n", hookName, hooksDir)
+	return nil
+}
+
+// runPrepareCommitMsgHook fills the commit message file with a generated message.
+// git passes the message file, the message source and, for amends, a commit.
+// Failures never block the commit, the user just gets git's usual empty message.
+func runPrepareCommitMsgHook(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "ai-git: %s hook called without a message file\n", hookName)
+		return
+	}
+	messageFile := args[0]
+
+	// Skip messages given with -m or -F, merges, squashes and reused commits
+	if len(args) > 1 && args[1] != "" && args[1] != "template" {
+		return
+	}
+
+	config, err := ai.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ai-git: not generating a commit message: %v\n", err)
+		return
+	}
+
+	changes, err := git.GetStagedChanges()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ai-git: not generating a commit message: %v\n", err)
+		return
+	}
+	if len(changes.Modified) == 0 && len(changes.Added) == 0 && len(changes.Deleted) == 0 {
+		return
+	}
+
+	message, err := generateCommitMessage(*config, changes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ai-git: not generating a commit message: %v\n", err)
+		return
+	}
+
+	// Keep git's comments and any template below the generated message
+	existing, err := os.ReadFile(messageFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ai-git: reading %s: %v\n", messageFile, err)
+		return
+	}
+	content := strings.TrimSpace(message) + "\n" + string(existing)
+	if err := os.WriteFile(messageFile, []byte(content), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "ai-git: writing %s: %v\n", messageFile, err)
+	}
+}
+
+// shellQuote quotes a string for use in a POSIX shell script
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
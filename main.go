@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,21 +13,47 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	config *ai.Config
+// aiCommands are ai-git's own commands that have no git equivalent
+var aiCommands = map[string]func() *cobra.Command{
+	"pr-description": newPRDescriptionCmd,
+	"changelog":      newChangelogCmd,
+	"review":         newReviewCmd,
+	"explain":        newExplainCmd,
+	"ask":            newAskCmd,
+	"resolve":        newResolveCmd,
+	"squash":         newSquashCmd,
+	"reword":         newRewordCmd,
+}
+
+// Exit codes of AI-assisted commands. When git itself fails, ai-git exits with git's exit code.
+const (
+	exitFailure          = 1 // Invalid arguments or other errors
+	exitGenerationFailed = 3 // The AI model could not generate the text, nothing was changed
 )
 
+// generationError marks an error of the AI model, so that the command exits with
+// exitGenerationFailed
+type generationError struct {
+	Err error
+}
+
+func (e *generationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *generationError) Unwrap() error {
+	return e.Err
+}
+
 func main() {
-	cfg, err := ai.LoadConfig()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
-	}
-	config = cfg
 	var rootCmd = &cobra.Command{
-		Use:                "ai-git [command]",
-		Short:              "AI-assisted git commands",
-		Long:               "AI-git is a git wrapper with AI capabilities for certain commands",
+		Use:   "ai-git [command]",
+		Short: "AI-assisted git commands",
+		Long: "AI-git is a git wrapper with AI capabilities for certain commands.\n\n" +
+			"In AI mode, --yes, --no-edit and --dry-run are handled by ai-git and not passed to git: " +
+			"ai-git commit -m --dry-run prints the generated message instead of running git commit --dry-run. " +
+			"Without AI mode they are passed to git unchanged.",
+		// Flags are parsed by parseGitArgs so that every git flag can be forwarded untouched
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
@@ -34,41 +61,54 @@ func main() {
 				return
 			}
 
-			if err == nil || strings.Contains(err.Error(), "flag needs an argument") {
+			if newCmd, ok := aiCommands[args[0]]; ok {
+				runAICommand(newCmd(), args[1:])
+				return
+			}
+
+			if isHookCommand(args) {
+				handleHook(args[1:])
+				return
+			}
+
+			parsed := parseGitArgs(args)
+			if parsed.AI {
+				assumeYes = parsed.Yes
 				// Handle specific commands
-				switch args[0] {
+				switch parsed.Command {
 				case "commit":
-					var message string
-					var all bool
-					cmd.Flags().StringVarP(&message, "message", "m", "", "Auto generate commit message")
-					cmd.Flags().BoolVarP(&all, "all", "a", false, "Auto add all to stage")
-					err := cmd.Flags().Parse(args)
-					if err != nil && strings.Contains(err.Error(), "flag needs an argument") && message == "" {
-						handleCommit(*config, all)
-						return
+					if parsed.Split {
+						handleSplitCommit(loadConfig(), parsed)
+					} else if parsed.HasOption("--amend") {
+						handleAmend(loadConfig(), parsed)
+					} else {
+						handleCommit(loadConfig(), parsed)
 					}
-				case "checkout":
-					var newBranch string
-					cmd.Flags().StringVarP(&newBranch, "newBranch", "b", "", "Auto generate branch name")
-					err := cmd.Flags().Parse(args)
-					if err != nil && strings.Contains(err.Error(), "flag needs an argument") && newBranch == "" {
-						handleCheckout(*config)
-						return
+					return
+				case "stash":
+					handleStash(loadConfig(), parsed)
+					return
+				case "tag":
+					if !parsed.Suggest {
+						fmt.Fprintf(os.Stderr, "Error: AI mode for git tag needs %s\n", suggestFlag)
+						os.Exit(1)
 					}
+					handleTagSuggest(loadConfig(), parsed)
+					return
+				case "merge":
+					handleMerge(loadConfig(), parsed)
+					return
+				case "checkout", "switch", "branch":
+					handleCheckout(loadConfig(), parsed)
+					return
+				default:
+					fmt.Fprintf(os.Stderr, "Error: %s is not supported for git %s\n", aiFlag, parsed.Command)
+					os.Exit(1)
 				}
 			}
+
 			// Fallback to standard git
-			gitCmd := exec.Command("git", args...)
-			gitCmd.Stdin = os.Stdin
-			gitCmd.Stdout = os.Stdout
-			gitCmd.Stderr = os.Stderr
-			if err := gitCmd.Run(); err != nil {
-				if exitError, ok := err.(*exec.ExitError); ok {
-					os.Exit(exitError.ExitCode())
-				}
-				fmt.Fprintf(os.Stderr, "Error executing git command: %v\n", err)
-				os.Exit(1)
-			}
+			runGit(withoutAIFlag(args))
 		},
 	}
 
@@ -78,9 +118,84 @@ func main() {
 	}
 }
 
-func handleCommit(config ai.Config, addAll bool) {
-	// Get detailed git changes information
-	changes, err := git.GetChanges()
+// runAICommand runs one of ai-git's own commands with its arguments
+func runAICommand(cmd *cobra.Command, args []string) {
+	cmd.SilenceUsage = true
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit code for an error of an AI-assisted command
+func exitCode(err error) int {
+	var genErr *generationError
+	if errors.As(err, &genErr) {
+		return exitGenerationFailed
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return exitFailure
+}
+
+// fatalGeneration logs a failure of the AI model and exits with exitGenerationFailed
+func fatalGeneration(format string, v ...any) {
+	log.Printf(format, v...)
+	os.Exit(exitGenerationFailed)
+}
+
+// fatalGit logs a failed git command and exits with git's exit code
+func fatalGit(err error, format string, v ...any) {
+	log.Printf(format, v...)
+	os.Exit(exitCode(err))
+}
+
+// runGit runs git with the given arguments attached to the terminal and exits
+// with git's exit code if it fails
+func runGit(args []string) {
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Stdin = os.Stdin
+	gitCmd.Stdout = os.Stdout
+	gitCmd.Stderr = os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitError.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "Error executing git command: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the AI configuration and exits if it is invalid or violates the policy.
+// It is only called for AI-assisted commands so plain git commands keep working.
+func loadConfig() ai.Config {
+	config, err := ai.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return *config
+}
+
+// handleCommit generates a commit message and runs git commit with the user's
+// original options and pathspecs, only injecting the message
+func handleCommit(config ai.Config, args gitArgs) {
+	// Concluding a merge needs a merge message rather than a description of the diff
+	state, err := git.GetMergeState()
+	if err != nil {
+		log.Fatalf("Error reading the merge state: %v", err)
+	}
+	if state != nil {
+		handleMergeCommit(config, args, state)
+		return
+	}
+
+	// Get detailed information about the changes being committed: the index, unless
+	// -a or pathspecs take them from the working tree
+	include := args.HasOption("--include") || args.HasOption("-i")
+	changes, err := git.GetCommitChanges(args.All, include, args.Positional...)
 	if err != nil {
 		log.Fatalf("Error getting git changes: %v", err)
 	}
@@ -91,83 +206,118 @@ func handleCommit(config ai.Config, addAll bool) {
 		return
 	}
 
+	// Generate commit message using AI
+	message, err := generateCommitMessage(config, changes)
+	if err != nil {
+		fatalGeneration("Error generating commit message: %v", err)
+	}
+	if args.DryRun {
+		fmt.Println(strings.TrimSpace(message))
+		return
+	}
+
+	// Let the user edit the AI-generated message
+	message, err = editText(message, "# AI-generated commit message. Save and close the editor to confirm the commit.\n#Or clear the file to cancel the commit.\n# Lines starting with # will be ignored.", "ai-git-commit-msg-*.txt")
+	if err != nil {
+		log.Fatalf("Error editing commit message: %v", err)
+	}
+
+	// If the message is empty, cancel the commit
+	if message == "" {
+		fmt.Println("Commit message is empty. Commit cancelled.")
+		return
+	}
+	// Execute git commit with the edited message
+	if err := runCommit(args, message); err != nil {
+		fatalGit(err, "Error executing git commit: %v", err)
+	}
+}
+
+// generateCommitMessage generates a commit message for the changes. It is shared by
+// ai-git commit and the prepare-commit-msg hook.
+func generateCommitMessage(config ai.Config, changes *git.Changes) (string, error) {
 	// Format changes for the prompt
-	formattedChanges := git.FormatChangesForPrompt(changes)
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		return "", fmt.Errorf("summarizing git changes: %w", err)
+	}
 
 	// Create prompt
 	prompt := fmt.Sprintf("Generate a concise git commit message based on these changes:\n\n%s, just give me the shortly commit message, you can add emojis.", formattedChanges)
 
-	// Generate commit message using AI
-	message, err := ai.GenerateCommitMessage(prompt, config)
+	return ai.GenerateCommitMessage(prompt, config)
+}
+
+// handleAmend regenerates the message of the HEAD commit from its full diff and
+// the changes being added to it, then runs git commit --amend with the result
+func handleAmend(config ai.Config, args gitArgs) {
+	// Get the changes of HEAD plus the newly staged changes
+	changes, err := git.GetAmendChanges(args.All, args.Positional...)
 	if err != nil {
-		log.Fatalf("Error generating commit message: %v", err)
+		log.Fatalf("Error getting git changes: %v", err)
 	}
 
-	// Write the AI-generated message to a temporary file for editing
-	tempFile, err := os.CreateTemp("", "ai-git-commit-msg-*.txt")
+	previousMessage, err := git.GetCommitMessage("HEAD")
 	if err != nil {
-		log.Fatalf("Error creating temporary file: %v", err)
+		log.Fatalf("Error reading the previous commit message: %v", err)
 	}
-	defer os.Remove(tempFile.Name()) // Clean up file when done
-
-	// Write AI-generated message to the file
-	fmt.Fprintf(tempFile, "%s\n\n# AI-generated commit message. Save and close the editor to confirm the commit.\n#Or clear the file to cancel the commit.\n# Lines starting with # will be ignored.", message)
-	tempFile.Close()
 
-	// Open the temporary file in the user's default editor
-	editor := os.Getenv("AI_GIT_EDITOR")
-	if editor == "" {
-		editor = os.Getenv("EDITOR")
-		if editor == "" {
-			editor = "vim" // Default to vim if no editor is set
-		}
+	// Format changes for the prompt
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		fatalGeneration("Error summarizing git changes: %v", err)
 	}
 
-	// Run the editor
-	editCmd := exec.Command(editor, tempFile.Name())
-	editCmd.Stdin = os.Stdin
-	editCmd.Stdout = os.Stdout
-	editCmd.Stderr = os.Stderr
-	if err := editCmd.Run(); err != nil {
-		log.Fatalf("Error opening editor: %v", err)
-	}
+	// Create prompt with the existing message as context
+	prompt := fmt.Sprintf("Rewrite the git commit message of a commit that is being amended. Its current message is:\n\n%s\n\nThese are all the changes of the amended commit:\n\n%s\nKeep what is still accurate in the current message, just give me the shortly commit message, you can add emojis.", previousMessage, formattedChanges)
 
-	// Read the edited message
-	editedMessageBytes, err := os.ReadFile(tempFile.Name())
+	// Generate commit message using AI
+	message, err := ai.GenerateCommitMessage(prompt, config)
 	if err != nil {
-		log.Fatalf("Error reading edited message: %v", err)
+		fatalGeneration("Error generating commit message: %v", err)
+	}
+	if args.DryRun {
+		fmt.Println(strings.TrimSpace(message))
+		return
 	}
 
-	// Process the edited message - remove comment lines
-	lines := strings.Split(string(editedMessageBytes), "\n")
-	var finalLines []string
-	for _, line := range lines {
-		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
-			finalLines = append(finalLines, line)
-		}
+	// Let the user edit the AI-generated message, showing the previous one for reference
+	comment := "# AI-generated message for the amended commit. Save and close the editor to confirm.\n# Or clear the file to cancel the amend.\n# Lines starting with # will be ignored.\n#\n# Previous message:\n#   " + strings.ReplaceAll(previousMessage, "\n", "\n#   ")
+	message, err = editText(message, comment, "ai-git-amend-msg-*.txt")
+	if err != nil {
+		log.Fatalf("Error editing commit message: %v", err)
 	}
-	message = strings.TrimSpace(strings.Join(finalLines, "\n"))
 
-	// If the message is empty, cancel the commit
+	// If the message is empty, cancel the amend
 	if message == "" {
-		fmt.Println("Commit message is empty. Commit cancelled.")
+		fmt.Println("Commit message is empty. Amend cancelled.")
 		return
 	}
-	arg := "-m"
-	if addAll {
-		arg = "-am"
+
+	// The options already contain --amend
+	if err := runCommit(args, message); err != nil {
+		fatalGit(err, "Error executing git commit --amend: %v", err)
 	}
-	// Execute git commit with the edited message
-	commitCmd := exec.Command("git", "commit", arg, message)
-	commitCmd.Stdout = os.Stdout
-	commitCmd.Stderr = os.Stderr
+}
 
-	if err := commitCmd.Run(); err != nil {
-		log.Fatalf("Error executing git commit: %v", err)
+// runCommit runs git commit with the user's options and pathspecs and the given message
+func runCommit(args gitArgs, message string) error {
+	commitArgs := append([]string{"commit"}, args.Options...)
+	commitArgs = append(commitArgs, "-m", message)
+	if len(args.Positional) > 0 {
+		commitArgs = append(commitArgs, "--")
+		commitArgs = append(commitArgs, args.Positional...)
 	}
+	commitCmd := exec.Command("git", commitArgs...)
+	commitCmd.Stdin = os.Stdin
+	commitCmd.Stdout = os.Stdout
+	commitCmd.Stderr = os.Stderr
+	return commitCmd.Run()
 }
 
-func handleCheckout(config ai.Config) {
+// handleCheckout generates a branch name and creates the branch with checkout -b/-B,
+// switch -c/-C or branch, keeping the user's options and start point
+func handleCheckout(config ai.Config, args gitArgs) {
 	// Get detailed git changes information
 	changes, err := git.GetChanges()
 	if err != nil {
@@ -175,79 +325,152 @@ func handleCheckout(config ai.Config) {
 	}
 
 	// Format changes for the prompt
-	formattedChanges := git.FormatChangesForPrompt(changes)
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		fatalGeneration("Error summarizing git changes: %v", err)
+	}
+
+	// Describe the work, the changes are empty when starting fresh work
+	var work strings.Builder
+	if args.From != "" {
+		work.WriteString("Description of the work: " + args.From + "\n\n")
+	}
+	if len(changes.Details) > 0 || len(changes.Unknown) > 0 {
+		work.WriteString(formattedChanges)
+	} else if args.From == "" {
+		work.WriteString("There are no changes yet.\n")
+	}
 
 	// Create prompt
-	prompt := fmt.Sprintf("Generate a concise git branch name based on these changes:\n\n%s\n\nPlease generate a branch name that follows git branch naming conventions (lowercase, hyphen-separated, descriptive). Just give me the branch name, no explanation needed.", formattedChanges)
+	types := config.Branch.Types
+	prompt := fmt.Sprintf("Generate a git branch name for this work:\n\n%s\nAnswer with exactly two lines and no explanation:\ntype: <one of %s>\nslug: <two to five lowercase words separated by hyphens describing the work>", work.String(), strings.Join(types, ", "))
 
 	// Generate branch name using AI
-	branchName, err := ai.GenerateBranchName(prompt, config)
+	answer, err := ai.GenerateBranchName(prompt, config)
 	if err != nil {
-		log.Fatalf("Error generating branch name: %v", err)
+		fatalGeneration("Error generating branch name: %v", err)
 	}
 
-	// Clean up the branch name
-	branchName = strings.TrimSpace(branchName)
-	branchName = strings.ToLower(branchName)
-	branchName = strings.ReplaceAll(branchName, " ", "-")
-
-	// Write the AI-generated branch name to a temporary file for editing
-	tempFile, err := os.CreateTemp("", "ai-git-branch-name-*.txt")
-	if err != nil {
-		log.Fatalf("Error creating temporary file: %v", err)
+	// Apply the team's naming template
+	fields := git.ParseBranchFields(answer, types)
+	fields.Ticket = git.ParseTicket(args.From)
+	branchName := git.SanitizeBranchName(git.FormatBranchName(config.Branch.Template, fields, config.Branch.MaxLength))
+	if branchName == "" {
+		fatalGeneration("Error generating branch name: no usable branch name in the AI response %q", answer)
 	}
-	defer os.Remove(tempFile.Name()) // Clean up file when done
 
-	// Write AI-generated branch name to the file
-	fmt.Fprintf(tempFile, "%s\n\n# AI-generated branch name. Save and close the editor to confirm.\n# Or clear the file to cancel.\n# Lines starting with # will be ignored.", branchName)
-	tempFile.Close()
+	comment := "# AI-generated branch name. Save and close the editor to confirm.\n# Or clear the file to cancel.\n# Lines starting with # will be ignored."
 
-	// Open the temporary file in the user's default editor
-	editor := os.Getenv("AI_GIT_EDITOR")
-	if editor == "" {
-		editor = os.Getenv("EDITOR")
-		if editor == "" {
-			editor = "vim" // Default to vim if no editor is set
+	// Suggest an alternative if the name is taken, unless the user asked to reset an existing branch
+	if !isForceCreate(args) {
+		collisions, err := git.FindBranchCollisions(branchName)
+		if err != nil {
+			log.Fatalf("Error listing branches: %v", err)
+		}
+		if len(collisions) > 0 {
+			suggestion, err := git.SuggestBranchName(branchName)
+			if err != nil {
+				log.Fatalf("Error suggesting a branch name: %v", err)
+			}
+			var refs []string
+			for _, collision := range collisions {
+				refs = append(refs, collision.Ref)
+			}
+			comment = fmt.Sprintf("# %q conflicts with existing branch %s,\n# suggesting %q instead.\n%s", branchName, strings.Join(refs, ", "), suggestion, comment)
+			branchName = suggestion
 		}
 	}
-
-	// Run the editor
-	editCmd := exec.Command(editor, tempFile.Name())
-	editCmd.Stdin = os.Stdin
-	editCmd.Stdout = os.Stdout
-	editCmd.Stderr = os.Stderr
-	if err := editCmd.Run(); err != nil {
-		log.Fatalf("Error opening editor: %v", err)
+	if args.DryRun {
+		fmt.Println(branchName)
+		return
 	}
 
-	// Read the edited branch name
-	editedNameBytes, err := os.ReadFile(tempFile.Name())
+	// Let the user edit the AI-generated branch name
+	branchName, err = editText(branchName, comment, "ai-git-branch-name-*.txt")
 	if err != nil {
-		log.Fatalf("Error reading edited branch name: %v", err)
-	}
-
-	// Process the edited branch name - remove comment lines
-	lines := strings.Split(string(editedNameBytes), "\n")
-	var finalLines []string
-	for _, line := range lines {
-		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
-			finalLines = append(finalLines, line)
-		}
+		log.Fatalf("Error editing branch name: %v", err)
 	}
-	branchName = strings.TrimSpace(strings.Join(finalLines, "\n"))
 
 	// If the branch name is empty, cancel the operation
 	if branchName == "" {
 		fmt.Println("Branch name is empty. Operation cancelled.")
 		return
 	}
+	if err := git.ValidateBranchName(branchName); err != nil {
+		log.Fatalf("Invalid branch name: %v", err)
+	}
 
-	// Execute git checkout -b with the branch name
-	checkoutCmd := exec.Command("git", "checkout", "-b", branchName)
+	// Execute git with the branch name
+	createArgs := branchCreateArgs(args, branchName)
+	checkoutCmd := exec.Command("git", createArgs...)
 	checkoutCmd.Stdout = os.Stdout
 	checkoutCmd.Stderr = os.Stderr
 
 	if err := checkoutCmd.Run(); err != nil {
-		log.Fatalf("Error executing git checkout -b: %v", err)
+		fatalGit(err, "Error executing git %s: %v", args.Command, err)
+	}
+}
+
+// isForceCreate reports whether the invocation resets an existing branch
+// (checkout -B, switch -C, branch -f)
+func isForceCreate(args gitArgs) bool {
+	switch args.AIOption {
+	case "-B", "-C", "--force-create":
+		return true
+	}
+	return args.Command == "branch" && (args.HasOption("-f") || args.HasOption("--force"))
+}
+
+// branchCreateArgs returns the git arguments that create the named branch for a
+// checkout, switch or branch invocation
+func branchCreateArgs(args gitArgs, branchName string) []string {
+	createArgs := []string{args.Command}
+	switch {
+	case args.Command == "branch":
+		createArgs = append(createArgs, args.Options...)
+		createArgs = append(createArgs, branchName)
+	case args.AIOption != "":
+		createArgs = append(createArgs, args.AIOption, branchName)
+		createArgs = append(createArgs, args.Options...)
+	case args.Command == "switch":
+		createArgs = append(createArgs, "-c", branchName)
+		createArgs = append(createArgs, args.Options...)
+	default:
+		createArgs = append(createArgs, "-b", branchName)
+		createArgs = append(createArgs, args.Options...)
+	}
+
+	// The positional arguments are the start point
+	if args.DoubleDash {
+		createArgs = append(createArgs, "--")
+	}
+	return append(createArgs, args.Positional...)
+}
+
+// describeChanges formats the changes for a prompt. When the formatted diff is larger
+// than the map-reduce threshold, each chunk is summarized separately and the
+// summaries are used instead of the raw diff, after the lists of changed files.
+func describeChanges(config ai.Config, changes *git.Changes) (string, error) {
+	// Files excluded by the policy never reach the prompt, not even by name
+	before := changes.Excluded
+	git.ExcludeChanges(changes, git.ParseIgnoreRules(config.Policy.ExcludePaths))
+	if excluded := changes.Excluded - before; excluded > 0 {
+		fmt.Fprintf(os.Stderr, "ai-git policy: %d file(s) excluded from the prompt by ai-git.excludePaths\n", excluded)
+	}
+
+	formattedChanges := git.FormatChangesForPrompt(changes)
+	if config.MapReduce.Threshold <= 0 || len(formattedChanges) <= config.MapReduce.Threshold {
+		return formattedChanges, nil
+	}
+
+	var chunks []ai.Chunk
+	for _, chunk := range git.SplitChanges(changes, config.MapReduce.ChunkSize) {
+		chunks = append(chunks, ai.Chunk{Name: chunk.Name, Content: chunk.Content})
+	}
+	fmt.Fprintf(os.Stderr, "Changes are large (%d bytes), summarizing them in %d chunks...\n", len(formattedChanges), len(chunks))
+	summaries, err := ai.SummarizeChunks(chunks, config, os.Stderr)
+	if err != nil {
+		return "", err
 	}
+	return git.FormatFileLists(changes) + summaries, nil
 }
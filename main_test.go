@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+)
+
+func TestDescribeChangesSummarized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": ai.Message{Role: "assistant", Content: "- summary"}}},
+		})
+	}))
+	defer server.Close()
+
+	config := ai.Config{
+		Type:      ai.ModelOpenAI,
+		OpenAI:    ai.OpenAIConfig{APIKey: "test", BaseURL: server.URL},
+		MapReduce: ai.MapReduceConfig{Threshold: 10, ChunkSize: 100},
+	}
+	changes := &git.Changes{
+		Modified: []string{"main.go"},
+		Unknown:  []string{"notes.txt"},
+		Omitted:  []string{"go.sum"},
+		Details:  map[string][]string{"main.go": {"+func main() {}"}},
+	}
+
+	described, err := describeChanges(config, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lists of files are kept, only the diff is replaced by the summaries
+	for _, want := range []string{"Modified files:\n- main.go\n", "Unknown files:\n- notes.txt\n", "- go.sum\n", "## .\n- summary\n"} {
+		if !strings.Contains(described, want) {
+			t.Errorf("describeChanges is missing %q:\n%s", want, described)
+		}
+	}
+	if strings.Contains(described, "func main") {
+		t.Errorf("describeChanges contains the raw diff:\n%s", described)
+	}
+}
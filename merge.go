@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+)
+
+// maxMergeCommits is the maximum number of merged commits listed in the prompt
+const maxMergeCommits = 50
+
+// mergeCommitOptions are git merge options that are passed on to the merge commit
+var mergeCommitOptions = []string{"--signoff", "--no-verify", "--gpg-sign", "-S", "--no-gpg-sign"}
+
+// handleMerge runs git merge without committing and commits the merge with a
+// generated message. A merge that stops on conflicts is left for the user to resolve.
+func handleMerge(config ai.Config, args gitArgs) {
+	switch {
+	case args.HasOption("--continue"):
+		handleCommit(config, gitArgs{Command: "commit", DryRun: args.DryRun})
+		return
+	case args.HasOption("--abort") || args.HasOption("--quit"):
+		runGit(append([]string{"merge"}, args.Options...))
+		return
+	case args.DryRun:
+		// Generating the message needs the merge result, which would change the working tree
+		log.Fatalf("Error: %s needs a merge in progress, run ai-git commit %s once git merge stopped", dryRunFlag, dryRunFlag)
+	}
+
+	// The message is generated afterwards, so git must neither commit nor ask for one
+	mergeArgs := []string{"merge", "--no-commit"}
+	var commitArgs gitArgs
+	commitArgs.Command = "commit"
+	for _, option := range args.Options {
+		switch option {
+		case "--commit", "--no-commit", "-e", "--edit":
+			continue
+		}
+		mergeArgs = append(mergeArgs, option)
+		for _, name := range mergeCommitOptions {
+			// Short options carry their value attached, like -Skeyid
+			if option == name || strings.HasPrefix(option, name+"=") || (len(name) == 2 && strings.HasPrefix(option, name)) {
+				commitArgs.Options = append(commitArgs.Options, option)
+			}
+		}
+	}
+	mergeArgs = append(mergeArgs, args.Positional...)
+
+	mergeCmd := exec.Command("git", mergeArgs...)
+	mergeCmd.Stdin = os.Stdin
+	mergeCmd.Stdout = os.Stdout
+	mergeCmd.Stderr = os.Stderr
+	mergeErr := mergeCmd.Run()
+
+	state, err := git.GetMergeState()
+	if err != nil {
+		log.Fatalf("Error reading the merge state: %v", err)
+	}
+	if mergeErr != nil {
+		if state != nil {
+			fmt.Fprintln(os.Stderr, "Resolve the conflicts, for example with \"ai-git resolve\", then run \"ai-git commit\" to commit the merge with a generated message.")
+		}
+		if exitError, ok := mergeErr.(*exec.ExitError); ok {
+			os.Exit(exitError.ExitCode())
+		}
+		fatalGit(mergeErr, "Error executing git merge: %v", mergeErr)
+	}
+
+	switch {
+	case state != nil:
+		handleMergeCommit(config, commitArgs, state)
+	case args.HasOption("--squash"):
+		// A squash merge only stages the changes, they are committed as a normal commit
+		handleCommit(config, commitArgs)
+	}
+	// Otherwise the merge was a fast-forward or already up to date
+}
+
+// handleMergeCommit generates a merge commit message from the merged commits and
+// the conflict resolutions, then commits the merge with the user's options
+func handleMergeCommit(config ai.Config, args gitArgs, state *git.MergeState) {
+	unmerged, err := git.GetUnmergedPaths()
+	if err != nil {
+		log.Fatalf("Error listing conflicts: %v", err)
+	}
+	if len(unmerged) > 0 {
+		log.Fatalf("Error: %d path(s) still have conflicts, resolve them (for example with \"ai-git resolve\") and stage them first", len(unmerged))
+	}
+
+	branch, err := git.GetCurrentBranch()
+	if err != nil {
+		branch = "HEAD"
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Write the commit message for a merge into %s.\n\n", branch)
+	if state.DefaultMessage != "" {
+		fmt.Fprintf(&prompt, "Git's default message is:\n%s\n\n", state.DefaultMessage)
+	}
+
+	for _, head := range state.Heads {
+		commits, err := git.GetCommits("HEAD.." + head)
+		if err != nil {
+			log.Fatalf("Error listing merged commits: %v", err)
+		}
+		fmt.Fprintf(&prompt, "Commits merged in from %s, oldest first:\n", head[:min(len(head), 7)])
+		for i, commit := range commits {
+			if i == maxMergeCommits {
+				fmt.Fprintf(&prompt, "- and %d more\n", len(commits)-maxMergeCommits)
+				break
+			}
+			prompt.WriteString("- " + commit.Subject + "\n")
+		}
+		prompt.WriteString("\n")
+	}
+
+	// Files excluded by the policy never reach the prompt, not even by name
+	policyRules := git.ParseIgnoreRules(config.Policy.ExcludePaths)
+	var conflicts []string
+	for _, path := range state.Conflicts {
+		if !policyRules.Match(path) {
+			conflicts = append(conflicts, path)
+		}
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintf(&prompt, "Conflicts were resolved in: %s\n\n", strings.Join(conflicts, ", "))
+		if len(state.Heads) == 1 {
+			resolution, err := git.GetResolutionDiff(state.Heads[0], conflicts)
+			if err != nil {
+				log.Fatalf("Error getting the conflict resolutions: %v", err)
+			}
+			if resolution != "" && (config.MapReduce.Threshold <= 0 || len(resolution) <= config.MapReduce.Threshold) {
+				fmt.Fprintf(&prompt, "How the conflicts were resolved, as a diff from the conflicted files:\n%s\n", resolution)
+			}
+		}
+	}
+
+	prompt.WriteString("Follow the usual merge message conventions: keep git's default message as the subject line, ")
+	prompt.WriteString("then a blank line and a short body summarizing what the merged commits bring in")
+	if len(conflicts) > 0 {
+		prompt.WriteString(" and how the conflicts were resolved")
+	}
+	prompt.WriteString(". Just give me the commit message, no explanation needed.")
+
+	fmt.Fprintln(os.Stderr, "Generating merge message...")
+	message, err := ai.GenerateMergeMessage(prompt.String(), config)
+	if err != nil {
+		fatalGeneration("Error generating merge message: %v", err)
+	}
+	if args.DryRun {
+		fmt.Println(trimCodeFence(message))
+		return
+	}
+
+	comment := "# AI-generated merge message. Save and close the editor to confirm the merge commit.\n# Or clear the file to cancel the commit, the merge stays in progress.\n# Lines starting with # will be ignored."
+	message, err = editText(trimCodeFence(message), comment, "ai-git-merge-msg-*.txt")
+	if err != nil {
+		log.Fatalf("Error editing merge message: %v", err)
+	}
+	if message == "" {
+		fmt.Println("Commit message is empty. Merge commit cancelled.")
+		return
+	}
+	if err := runCommit(args, message); err != nil {
+		fatalGit(err, "Error executing git commit: %v", err)
+	}
+}
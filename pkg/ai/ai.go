@@ -81,44 +81,140 @@ type QwenResponse struct {
 	} `json:"choices"`
 }
 
+// commitSystemPrompt is the system prompt used for commit messages and branch names
+const commitSystemPrompt = "You are a helpful assistant that generates concise and descriptive git commit message based on the changes provided. Please generate shortly."
+
 // GenerateCommitMessage generates a commit message using the configured AI model
 func GenerateCommitMessage(prompt string, config Config) (string, error) {
-	switch config.Type {
-	case ModelOpenAI:
-		return generateWithOpenAI(prompt, config.OpenAI)
-	case ModelOllama:
-		return generateWithOllama(prompt, config.Ollama)
-	case ModelAnthropic:
-		return generateWithAnthropic(prompt, config.Anthropic)
-	case ModelDeepSeek:
-		return generateWithDeepSeek(prompt, config.DeepSeek)
-	case ModelQwen:
-		return generateWithQwen(prompt, config.Qwen)
-	default:
-		return "", fmt.Errorf("unsupported model type: %s", config.Type)
-	}
+	return generate(commitSystemPrompt, prompt, config)
 }
 
 // GenerateBranchName generates a branch name using the configured AI model
 func GenerateBranchName(prompt string, config Config) (string, error) {
+	return generate(commitSystemPrompt, prompt, config)
+}
+
+// mergeSystemPrompt is the system prompt used for merge commit messages
+const mergeSystemPrompt = "You are a helpful assistant that writes git merge commit messages, summarizing the merged work and how conflicts were resolved based on the commits provided."
+
+// GenerateMergeMessage generates a merge commit message using the configured AI model
+func GenerateMergeMessage(prompt string, config Config) (string, error) {
+	return generate(mergeSystemPrompt, prompt, config)
+}
+
+// stashSystemPrompt is the system prompt used for stash messages and summaries
+const stashSystemPrompt = "You are a helpful assistant that describes stashed work in progress briefly, so that it can be recognized later, based on the changes provided."
+
+// GenerateStashMessage describes stashed changes using the configured AI model
+func GenerateStashMessage(prompt string, config Config) (string, error) {
+	return generate(stashSystemPrompt, prompt, config)
+}
+
+// tagSystemPrompt is the system prompt used for release tag messages
+const tagSystemPrompt = "You are a helpful assistant that writes concise release notes for annotated git tags, based on the changes provided."
+
+// GenerateTagMessage generates an annotated tag message using the configured AI model
+func GenerateTagMessage(prompt string, config Config) (string, error) {
+	return generate(tagSystemPrompt, prompt, config)
+}
+
+// commitPlanSystemPrompt is the system prompt used to split changes into commits
+const commitPlanSystemPrompt = "You are a helpful assistant that splits mixed changes into small, logically separate git commits, each with a concise commit message, based on the changes provided."
+
+// GenerateCommitPlan groups changes into separate commits using the configured AI model
+func GenerateCommitPlan(prompt string, config Config) (string, error) {
+	return generate(commitPlanSystemPrompt, prompt, config)
+}
+
+// prSystemPrompt is the system prompt used for pull request descriptions
+const prSystemPrompt = "You are a helpful assistant that writes clear pull request descriptions in Markdown for reviewers, based on the commits and changes provided."
+
+// GeneratePRDescription generates a pull request description using the configured AI model
+func GeneratePRDescription(prompt string, config Config) (string, error) {
+	return generate(prSystemPrompt, prompt, config)
+}
+
+// changelogSystemPrompt is the system prompt used to classify commits for a changelog
+const changelogSystemPrompt = "You are a helpful assistant that writes changelog entries for the users of a project, based on the commit messages provided."
+
+// GenerateChangelogEntries classifies commits into changelog entries using the configured AI model
+func GenerateChangelogEntries(prompt string, config Config) (string, error) {
+	return generate(changelogSystemPrompt, prompt, config)
+}
+
+// reviewSystemPrompt is the system prompt used for code reviews
+const reviewSystemPrompt = "You are an experienced code reviewer. Point out bugs, security issues and risky changes in the diff provided, and do not comment on style preferences."
+
+// GenerateReview reviews changes using the configured AI model
+func GenerateReview(prompt string, config Config) (string, error) {
+	return generate(reviewSystemPrompt, prompt, config)
+}
+
+// explainSystemPrompt is the system prompt used to explain commits
+const explainSystemPrompt = "You are a helpful assistant that explains git history in plain language, based on the commit messages and changes provided."
+
+// GenerateExplanation explains commits using the configured AI model
+func GenerateExplanation(prompt string, config Config) (string, error) {
+	return generate(explainSystemPrompt, prompt, config)
+}
+
+// askSystemPrompt is the system prompt used to answer questions with git commands
+const askSystemPrompt = "You are a git expert who answers questions with the git commands that solve them, based on the repository state provided. You never suggest destructive commands when a safe alternative exists."
+
+// GenerateGitCommands answers a question with git commands using the configured AI model
+func GenerateGitCommands(prompt string, config Config) (string, error) {
+	return generate(askSystemPrompt, prompt, config)
+}
+
+// resolveSystemPrompt is the system prompt used to resolve merge conflicts
+const resolveSystemPrompt = "You are an experienced developer who resolves merge conflicts carefully, keeping the intent of both sides and producing code that compiles."
+
+// GenerateConflictResolution resolves a merge conflict using the configured AI model
+func GenerateConflictResolution(prompt string, config Config) (string, error) {
+	return generate(resolveSystemPrompt, prompt, config)
+}
+
+// generate sends the prompt with the given system prompt to the configured AI model.
+// The model policy is enforced and secrets are masked before the prompt leaves the
+// machine. Excluded paths are removed by the callers, from the file lists the prompt
+// is built from.
+func generate(systemPrompt, prompt string, config Config) (string, error) {
+	if err := config.CheckPolicy(); err != nil {
+		return "", err
+	}
+
+	if config.Redaction.Enabled {
+		redacted, findings, err := Redact(prompt, config.Redaction)
+		if err != nil {
+			return "", err
+		}
+		if len(findings) > 0 {
+			if config.Redaction.Block {
+				return "", &SecretsError{Findings: findings}
+			}
+			fmt.Fprintf(os.Stderr, "Warning: masked potential secrets in the changes before sending them to the AI model (%s)\n", formatFindings(findings))
+			prompt = redacted
+		}
+	}
+
 	switch config.Type {
 	case ModelOpenAI:
-		return generateWithOpenAI(prompt, config.OpenAI)
+		return generateWithOpenAI(systemPrompt, prompt, config.OpenAI)
 	case ModelOllama:
-		return generateWithOllama(prompt, config.Ollama)
+		return generateWithOllama(systemPrompt, prompt, config.Ollama)
 	case ModelAnthropic:
-		return generateWithAnthropic(prompt, config.Anthropic)
+		return generateWithAnthropic(systemPrompt, prompt, config.Anthropic)
 	case ModelDeepSeek:
-		return generateWithDeepSeek(prompt, config.DeepSeek)
+		return generateWithDeepSeek(systemPrompt, prompt, config.DeepSeek)
 	case ModelQwen:
-		return generateWithQwen(prompt, config.Qwen)
+		return generateWithQwen(systemPrompt, prompt, config.Qwen)
 	default:
 		return "", fmt.Errorf("unsupported model type: %s", config.Type)
 	}
 }
 
-// generateWithOpenAI generates a commit message using OpenAI
-func generateWithOpenAI(prompt string, config OpenAIConfig) (string, error) {
+// generateWithOpenAI generates a response using OpenAI
+func generateWithOpenAI(systemPrompt, prompt string, config OpenAIConfig) (string, error) {
 	apiKey := config.APIKey
 	if apiKey == "" {
 		apiKey = os.Getenv("OPENAI_API_KEY")
@@ -132,7 +228,7 @@ func generateWithOpenAI(prompt string, config OpenAIConfig) (string, error) {
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a helpful assistant that generates concise and descriptive git commit message based on the changes provided. Please generate shortly.",
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
@@ -184,8 +280,8 @@ func generateWithOpenAI(prompt string, config OpenAIConfig) (string, error) {
 	return openAIResp.Choices[0].Message.Content, nil
 }
 
-// generateWithOllama generates a commit message using Ollama
-func generateWithOllama(prompt string, config OllamaConfig) (string, error) {
+// generateWithOllama generates a response using Ollama
+func generateWithOllama(systemPrompt, prompt string, config OllamaConfig) (string, error) {
 	baseURL := config.BaseURL
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
@@ -196,7 +292,7 @@ func generateWithOllama(prompt string, config OllamaConfig) (string, error) {
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a helpful assistant that generates concise and descriptive git commit message based on the changes provided. Please generate shortly.",
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
@@ -238,8 +334,8 @@ func generateWithOllama(prompt string, config OllamaConfig) (string, error) {
 	return ollamaResp.Message.Content, nil
 }
 
-// generateWithAnthropic generates a commit message using Anthropic
-func generateWithAnthropic(prompt string, config AnthropicConfig) (string, error) {
+// generateWithAnthropic generates a response using Anthropic
+func generateWithAnthropic(systemPrompt, prompt string, config AnthropicConfig) (string, error) {
 	apiKey := config.APIKey
 	if apiKey == "" {
 		apiKey = os.Getenv("ANTHROPIC_API_KEY")
@@ -265,7 +361,7 @@ func generateWithAnthropic(prompt string, config AnthropicConfig) (string, error
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a helpful assistant that generates concise and descriptive git commit message based on the changes provided. Please generate shortly.",
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
@@ -320,8 +416,8 @@ func generateWithAnthropic(prompt string, config AnthropicConfig) (string, error
 	return anthropicResp.Content[0].Text, nil
 }
 
-// generateWithDeepSeek generates a commit message using DeepSeek
-func generateWithDeepSeek(prompt string, config DeepSeekConfig) (string, error) {
+// generateWithDeepSeek generates a response using DeepSeek
+func generateWithDeepSeek(systemPrompt, prompt string, config DeepSeekConfig) (string, error) {
 	apiKey := config.APIKey
 	if apiKey == "" {
 		apiKey = os.Getenv("DEEPSEEK_API_KEY")
@@ -340,7 +436,7 @@ func generateWithDeepSeek(prompt string, config DeepSeekConfig) (string, error)
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a helpful assistant that generates concise and descriptive git commit message based on the changes provided. Please generate shortly.",
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
@@ -387,8 +483,8 @@ func generateWithDeepSeek(prompt string, config DeepSeekConfig) (string, error)
 	return deepSeekResp.Choices[0].Message.Content, nil
 }
 
-// generateWithQwen generates a commit message using Qwen
-func generateWithQwen(prompt string, config QwenConfig) (string, error) {
+// generateWithQwen generates a response using Qwen
+func generateWithQwen(systemPrompt, prompt string, config QwenConfig) (string, error) {
 	apiKey := config.APIKey
 	if apiKey == "" {
 		apiKey = os.Getenv("QWEN_API_KEY")
@@ -407,7 +503,7 @@ func generateWithQwen(prompt string, config QwenConfig) (string, error) {
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a helpful assistant that generates concise and descriptive git commit message based on the changes provided. Please generate shortly.",
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
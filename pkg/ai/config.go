@@ -3,6 +3,9 @@ package ai
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // ModelType represents the type of AI model
@@ -24,6 +27,11 @@ type Config struct {
 	Anthropic AnthropicConfig `yaml:"anthropic,omitempty" json:"anthropic,omitempty"`
 	DeepSeek  DeepSeekConfig  `yaml:"deepseek,omitempty" json:"deepseek,omitempty"`
 	Qwen      QwenConfig      `yaml:"qwen,omitempty" json:"qwen,omitempty"`
+	MapReduce MapReduceConfig `yaml:"map_reduce,omitempty" json:"map_reduce,omitempty"`
+	Redaction RedactionConfig `yaml:"redaction,omitempty" json:"redaction,omitempty"`
+	Policy    PolicyConfig    `yaml:"policy,omitempty" json:"policy,omitempty"`
+	Branch    BranchConfig    `yaml:"branch,omitempty" json:"branch,omitempty"`
+	History   HistoryConfig   `yaml:"history,omitempty" json:"history,omitempty"`
 }
 
 // OpenAIConfig holds OpenAI-specific configuration
@@ -60,6 +68,40 @@ type QwenConfig struct {
 	BaseURL string `yaml:"base_url" json:"base_url"`
 }
 
+// MapReduceConfig holds the settings for hierarchical summarization of large changes
+type MapReduceConfig struct {
+	Threshold int    `yaml:"threshold" json:"threshold"`   // Prompt size in bytes above which changes are summarized in chunks
+	ChunkSize int    `yaml:"chunk_size" json:"chunk_size"` // Maximum size in bytes of a single chunk
+	Workers   int    `yaml:"workers" json:"workers"`       // Maximum number of concurrent summarization requests
+	CacheDir  string `yaml:"cache_dir" json:"cache_dir"`   // Directory where partial summaries are cached
+}
+
+// RedactionConfig holds the settings for masking secrets before prompts are sent
+type RedactionConfig struct {
+	Enabled  bool     `yaml:"enabled" json:"enabled"`   // Mask detected secrets in prompts
+	Block    bool     `yaml:"block" json:"block"`       // Refuse to send prompts that contain secrets
+	Patterns []string `yaml:"patterns" json:"patterns"` // Additional regular expressions to mask
+}
+
+// PolicyConfig restricts where repository content may be sent
+type PolicyConfig struct {
+	AllowedTypes []ModelType `yaml:"allowed_types" json:"allowed_types"` // Model types allowed to receive prompts, empty allows all
+	LocalOnly    bool        `yaml:"local_only" json:"local_only"`       // Only allow models served from a loopback address
+	ExcludePaths []string    `yaml:"exclude_paths" json:"exclude_paths"` // Path globs that are never included in prompts
+}
+
+// BranchConfig holds the team conventions for generated branch names
+type BranchConfig struct {
+	Template  string   `yaml:"template" json:"template"`     // Naming template with {type}, {ticket} and {slug}
+	Types     []string `yaml:"types" json:"types"`           // Allowed values for {type}
+	MaxLength int      `yaml:"max_length" json:"max_length"` // Maximum length of the branch name, 0 for no limit
+}
+
+// HistoryConfig holds the settings for commands that rewrite history
+type HistoryConfig struct {
+	ProtectedBranches []string `yaml:"protected_branches" json:"protected_branches"` // Branch globs whose pushed commits are never rewritten without --force
+}
+
 // LoadConfig loads the configuration from the specified file
 func LoadConfig() (*Config, error) {
 	config := Config{
@@ -88,6 +130,27 @@ func LoadConfig() (*Config, error) {
 			Model:   getEnvWithDefault("QWEN_MODEL", "qwen-max"),
 			BaseURL: getEnvWithDefault("QWEN_BASE_URL", "https://dashscope.aliyuncs.com/api/v1/services/aigc/text-generation/generation"),
 		},
+		MapReduce: MapReduceConfig{
+			Threshold: getEnvIntWithDefault("AI_GIT_MAP_REDUCE_THRESHOLD", 16000),
+			ChunkSize: getEnvIntWithDefault("AI_GIT_CHUNK_SIZE", 8000),
+			Workers:   getEnvIntWithDefault("AI_GIT_WORKERS", 4),
+			CacheDir:  getEnvWithDefault("AI_GIT_CACHE_DIR", defaultCacheDir()),
+		},
+		Redaction: RedactionConfig{
+			Enabled:  getEnvBoolWithDefault("AI_GIT_REDACT", true),
+			Block:    getEnvBoolWithDefault("AI_GIT_REDACT_BLOCK", false),
+			Patterns: getEnvListWithDefault("AI_GIT_REDACT_PATTERNS", ";", nil),
+		},
+		Branch: BranchConfig{
+			Template:  getEnvWithDefault("AI_GIT_BRANCH_TEMPLATE", "{type}/{ticket}-{slug}"),
+			Types:     getEnvListWithDefault("AI_GIT_BRANCH_TYPES", ",", []string{"feature", "fix", "chore", "docs", "refactor", "test"}),
+			MaxLength: getEnvIntWithDefault("AI_GIT_BRANCH_MAX_LENGTH", 60),
+		},
+		History: HistoryConfig{
+			// Branches protected in the repository git config stay protected in every shell
+			ProtectedBranches: append(gitConfigList("ai-git.protectedBranches"),
+				getEnvListWithDefault("AI_GIT_PROTECTED_BRANCHES", ",", []string{"main", "master"})...),
+		},
 	}
 
 	// Set default values if needed
@@ -124,9 +187,62 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("unsupported model type: %s", config.Type)
 	}
 
+	if config.MapReduce.ChunkSize <= 0 {
+		config.MapReduce.ChunkSize = 8000
+	}
+	if config.MapReduce.Workers <= 0 {
+		config.MapReduce.Workers = 1
+	}
+
+	config.Policy = loadPolicy()
+	if err := config.CheckPolicy(); err != nil {
+		return nil, err
+	}
+
+	// Validate custom redaction patterns early instead of on the first prompt
+	if _, _, err := Redact("", config.Redaction); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+// ModelName returns the model name configured for the selected model type
+func (c Config) ModelName() string {
+	switch c.Type {
+	case ModelOpenAI:
+		return c.OpenAI.Model
+	case ModelOllama:
+		return c.Ollama.Model
+	case ModelAnthropic:
+		return c.Anthropic.Model
+	case ModelDeepSeek:
+		return c.DeepSeek.Model
+	case ModelQwen:
+		return c.Qwen.Model
+	default:
+		return ""
+	}
+}
+
+// BaseURL returns the endpoint configured for the selected model type
+func (c Config) BaseURL() string {
+	switch c.Type {
+	case ModelOpenAI:
+		return c.OpenAI.BaseURL
+	case ModelOllama:
+		return c.Ollama.BaseURL
+	case ModelAnthropic:
+		return c.Anthropic.BaseURL
+	case ModelDeepSeek:
+		return c.DeepSeek.BaseURL
+	case ModelQwen:
+		return c.Qwen.BaseURL
+	default:
+		return ""
+	}
+}
+
 // Helper to get environment variable with default fallback
 func getEnvWithDefault(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -135,3 +251,45 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// Helper to get integer environment variable with default fallback
+func getEnvIntWithDefault(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// Helper to get boolean environment variable with default fallback
+func getEnvBoolWithDefault(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// Helper to get a separated list from an environment variable with default fallback
+func getEnvListWithDefault(key, sep string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// defaultCacheDir returns the per-user cache directory for ai-git
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ai-git")
+}
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// summarySystemPrompt is the system prompt used to summarize a single chunk of a large change
+const summarySystemPrompt = "You are a helpful assistant that summarizes code changes. Describe what changed and why in a few short bullet points. Do not repeat the diff."
+
+// Chunk represents a part of a large change that is summarized on its own
+type Chunk struct {
+	Name    string
+	Content string
+}
+
+// SummarizeChunks summarizes each chunk with a separate model call and returns the
+// combined summaries, formatted for use in a follow-up prompt.
+// At most config.MapReduce.Workers calls run concurrently. Progress is written to
+// progress if it is not nil, and partial summaries are cached in config.MapReduce.CacheDir.
+func SummarizeChunks(chunks []Chunk, config Config, progress io.Writer) (string, error) {
+	workers := config.MapReduce.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	summaries := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, chunk Chunk) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			summary, cached, err := summarizeChunk(chunk, config)
+			summaries[i] = summary
+			errs[i] = err
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if progress == nil {
+				return
+			}
+			switch {
+			case err != nil:
+				fmt.Fprintf(progress, "[%d/%d] failed to summarize %s: %v\n", done, len(chunks), chunk.Name, err)
+			case cached:
+				fmt.Fprintf(progress, "[%d/%d] summarized %s (cached)\n", done, len(chunks), chunk.Name)
+			default:
+				fmt.Fprintf(progress, "[%d/%d] summarized %s\n", done, len(chunks), chunk.Name)
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return "", fmt.Errorf("summarizing %s: %w", chunks[i].Name, err)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Summaries of the changes, grouped by file or directory:\n\n")
+	for i, chunk := range chunks {
+		sb.WriteString("## " + chunk.Name + "\n")
+		sb.WriteString(strings.TrimSpace(summaries[i]) + "\n\n")
+	}
+	return sb.String(), nil
+}
+
+// summarizeChunk summarizes a single chunk, using the cache when possible.
+// The returned bool reports whether the summary came from the cache.
+func summarizeChunk(chunk Chunk, config Config) (string, bool, error) {
+	cachePath := summaryCachePath(chunk, config)
+	if cachePath != "" {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			return string(data), true, nil
+		}
+	}
+
+	prompt := fmt.Sprintf("Summarize these changes to %s:\n\n%s", chunk.Name, chunk.Content)
+	summary, err := generate(summarySystemPrompt, prompt, config)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Caching is best effort, a failed write only costs a model call next time
+	if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			_ = os.WriteFile(cachePath, []byte(summary), 0o644)
+		}
+	}
+	return summary, false, nil
+}
+
+// summaryCachePath returns the cache file for a chunk summary, or "" if caching is disabled.
+// The key covers the model so that switching models does not reuse stale summaries.
+func summaryCachePath(chunk Chunk, config Config) string {
+	if config.MapReduce.CacheDir == "" {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", config.Type, config.ModelName(), summarySystemPrompt, chunk.Content)
+	return filepath.Join(config.MapReduce.CacheDir, "summaries", hex.EncodeToString(h.Sum(nil))+".txt")
+}
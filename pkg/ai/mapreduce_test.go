@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSummaryServer starts an OpenAI compatible server that answers "summary of <name>"
+// for each chunk, and returns a configuration that uses it. The first chunks are
+// answered last, so that results arrive out of order.
+func newSummaryServer(t *testing.T, requests, maxActive *int32) Config {
+	t.Helper()
+	var active int32
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		mu.Lock()
+		if n > *maxActive {
+			*maxActive = n
+		}
+		mu.Unlock()
+
+		var request OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		prompt := request.Messages[len(request.Messages)-1].Content
+		name, _, _ := strings.Cut(strings.TrimPrefix(prompt, "Summarize these changes to "), ":")
+		var number int
+		fmt.Sscanf(name, "chunk %d", &number)
+		time.Sleep(time.Duration(10-number) * 5 * time.Millisecond)
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": Message{Role: "assistant", Content: "summary of " + name}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return Config{
+		Type:      ModelOpenAI,
+		OpenAI:    OpenAIConfig{APIKey: "test", Model: "test", BaseURL: server.URL},
+		MapReduce: MapReduceConfig{Workers: 3, CacheDir: t.TempDir()},
+	}
+}
+
+func TestSummarizeChunks(t *testing.T) {
+	var requests, maxActive int32
+	config := newSummaryServer(t, &requests, &maxActive)
+
+	var chunks []Chunk
+	for i := 1; i <= 6; i++ {
+		chunks = append(chunks, Chunk{Name: fmt.Sprintf("chunk %d", i), Content: fmt.Sprintf("+line %d\n", i)})
+	}
+
+	// The summaries are in the order of the chunks, whatever order they finish in
+	summaries, err := SummarizeChunks(chunks, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Summaries of the changes, grouped by file or directory:\n\n"
+	for i := 1; i <= 6; i++ {
+		want += fmt.Sprintf("## chunk %d\nsummary of chunk %d\n\n", i, i)
+	}
+	if summaries != want {
+		t.Errorf("SummarizeChunks = %q, want %q", summaries, want)
+	}
+	if n := atomic.LoadInt32(&requests); n != 6 {
+		t.Errorf("%d requests, want 6", n)
+	}
+	if maxActive > int32(config.MapReduce.Workers) {
+		t.Errorf("%d concurrent requests, want at most %d", maxActive, config.MapReduce.Workers)
+	}
+
+	// A second run is answered from the cache, a changed chunk is summarized again
+	chunks[2].Content = "+changed\n"
+	var progress bytes.Buffer
+	if _, err := SummarizeChunks(chunks, config, &progress); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 7 {
+		t.Errorf("%d requests after the second run, want 7", n)
+	}
+	if cached := strings.Count(progress.String(), "(cached)"); cached != 5 {
+		t.Errorf("%d cached summaries, want 5:\n%s", cached, progress.String())
+	}
+}
+
+func TestSummarizeChunksError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := Config{Type: ModelOpenAI, OpenAI: OpenAIConfig{APIKey: "test", BaseURL: server.URL}}
+	_, err := SummarizeChunks([]Chunk{{Name: "a", Content: "+a\n"}}, config, nil)
+	if err == nil || !strings.Contains(err.Error(), "summarizing a") {
+		t.Errorf("SummarizeChunks = %v, want an error for chunk a", err)
+	}
+}
@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// PolicyError is returned when sending a prompt would violate the privacy policy.
+// Its message is the audit message shown to the user.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return "ai-git policy: " + e.Reason
+}
+
+// PolicyFile is the tracked file at the root of the repository that holds its
+// privacy policy, in git config format
+const PolicyFile = ".aigitpolicy"
+
+// loadPolicy loads the privacy policy. Settings in the policy file as committed in HEAD
+// (ai-git.allowedModels, ai-git.localOnly, ai-git.excludePaths) take precedence over
+// the environment, so that a repository policy cannot be loosened per shell, by
+// global or local git config, or by uncommitted edits. The environment can only
+// tighten it: AI_GIT_LOCAL_ONLY and AI_GIT_EXCLUDE_PATHS add to the policy, and
+// AI_GIT_ALLOWED_MODELS applies when the file does not restrict the model types.
+func loadPolicy() PolicyConfig {
+	var policy PolicyConfig
+
+	allowed := policyList("ai-git.allowedModels")
+	if len(allowed) == 0 {
+		allowed = getEnvListWithDefault("AI_GIT_ALLOWED_MODELS", ",", nil)
+	}
+	for _, modelType := range allowed {
+		policy.AllowedTypes = append(policy.AllowedTypes, ModelType(strings.ToLower(modelType)))
+	}
+
+	policy.LocalOnly = getEnvBoolWithDefault("AI_GIT_LOCAL_ONLY", false)
+	for _, value := range policyList("ai-git.localOnly") {
+		if localOnly, err := strconv.ParseBool(value); err == nil && localOnly {
+			policy.LocalOnly = true
+		}
+	}
+
+	policy.ExcludePaths = append(policyList("ai-git.excludePaths"), getEnvListWithDefault("AI_GIT_EXCLUDE_PATHS", ",", nil)...)
+
+	return policy
+}
+
+// CheckPolicy returns a PolicyError if the configured model may not receive prompts
+func (c Config) CheckPolicy() error {
+	target := fmt.Sprintf("%s (%s)", c.Type, c.BaseURL())
+
+	if len(c.Policy.AllowedTypes) > 0 {
+		allowed := false
+		for _, modelType := range c.Policy.AllowedTypes {
+			if modelType == c.Type {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return &PolicyError{Reason: fmt.Sprintf("refusing to send repository content to %s, model type %q is not in the allowed list %v", target, c.Type, c.Policy.AllowedTypes)}
+		}
+	}
+
+	if c.Policy.LocalOnly && !isLoopbackURL(c.BaseURL()) {
+		return &PolicyError{Reason: fmt.Sprintf("refusing to send repository content to %s, the local-only policy requires a localhost endpoint", target)}
+	}
+
+	return nil
+}
+
+// isLoopbackURL reports whether the URL points at the local machine
+func isLoopbackURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// policyList returns all values of a key in the policy file committed in HEAD,
+// split on commas
+func policyList(key string) []string {
+	cmd := exec.Command("git", "config", "--blob", "HEAD:"+PolicyFile, "--get-all", key)
+	output, err := cmd.Output()
+	if err != nil {
+		// The key is not set, there is no policy file or we are not in a repository
+		return nil
+	}
+	return splitConfigValues(string(output))
+}
+
+// gitConfigList returns all values of a git config key, split on commas
+func gitConfigList(key string) []string {
+	cmd := exec.Command("git", "config", "--get-all", key)
+	output, err := cmd.Output()
+	if err != nil {
+		// The key is not set or we are not in a repository
+		return nil
+	}
+	return splitConfigValues(string(output))
+}
+
+// splitConfigValues splits the output of git config --get-all into values, one per
+// line and separated by commas
+func splitConfigValues(output string) []string {
+	var values []string
+	for _, line := range strings.Split(output, "\n") {
+		for _, value := range strings.Split(line, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// runTestGit runs git in the working directory and fails the test on error
+func runTestGit(t *testing.T, args ...string) {
+	t.Helper()
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("AI_GIT_ALLOWED_MODELS", "openai")
+	t.Setenv("AI_GIT_LOCAL_ONLY", "")
+	t.Setenv("AI_GIT_EXCLUDE_PATHS", "*.key")
+	runTestGit(t, "init", "-q")
+	runTestGit(t, "config", "user.name", "Test")
+	runTestGit(t, "config", "user.email", "test@example.com")
+
+	// Without a policy file the environment applies and git config is ignored
+	runTestGit(t, "config", "ai-git.localOnly", "true")
+	policy := loadPolicy()
+	if !reflect.DeepEqual(policy.AllowedTypes, []ModelType{"openai"}) || policy.LocalOnly {
+		t.Errorf("without a policy file: %+v", policy)
+	}
+
+	policyFile := "[ai-git]\n\tallowedModels = ollama\n\tlocalOnly = true\n\texcludePaths = secrets/, *.pem\n"
+	if err := os.WriteFile(PolicyFile, []byte(policyFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, "add", PolicyFile)
+	runTestGit(t, "commit", "-q", "-m", "Add policy")
+
+	// An uncommitted edit does not loosen the policy
+	if err := os.WriteFile(PolicyFile, []byte("[ai-git]\n\tlocalOnly = false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy = loadPolicy()
+	if !reflect.DeepEqual(policy.AllowedTypes, []ModelType{"ollama"}) {
+		t.Errorf("AllowedTypes = %v, want [ollama]", policy.AllowedTypes)
+	}
+	if !policy.LocalOnly {
+		t.Error("LocalOnly = false, want true")
+	}
+	if want := []string{"secrets/", "*.pem", "*.key"}; !reflect.DeepEqual(policy.ExcludePaths, want) {
+		t.Errorf("ExcludePaths = %q, want %q", policy.ExcludePaths, want)
+	}
+}
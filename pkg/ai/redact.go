@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretRule detects one kind of secret. If group is non-zero only that
+// submatch is masked, so the surrounding key name stays readable.
+type secretRule struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+	check func(string) bool
+}
+
+// builtinSecretRules are the secret formats detected in every prompt
+var builtinSecretRules = []secretRule{
+	{kind: "private-key", re: regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----.*?(-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----|\z)`)},
+	{kind: "aws-access-key", re: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[0-9A-Z]{16}\b`)},
+	{kind: "aws-secret-key", re: regexp.MustCompile(`(?i)aws[\w.-]*secret[\w.-]*["']?\s*(?::=|=>|[:=])\s*["']?([A-Za-z0-9/+]{40})\b`), group: 1},
+	{kind: "github-token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "slack-token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{kind: "slack-webhook", re: regexp.MustCompile(`https://hooks\.slack\.com/(?:services|workflows)/[A-Za-z0-9/_-]+`)},
+	{kind: "openai-key", re: regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}`)},
+	{kind: "google-api-key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{kind: "credential-assignment", re: regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api_?key|access_?key|credential)[\w.-]*["']?\s*(?::=|=>|[:=])\s*["']([^"'\s]{8,})["']`), group: 1},
+	// Unquoted values are only trusted on dotenv-style lines, like API_KEY=abc123, so
+	// code such as maxTokens = defaultMaxTokens is left alone
+	{kind: "credential-assignment", re: regexp.MustCompile(`(?m)^[+\- ]?(?:export\s+)?[A-Z0-9_]*(?:KEY|SECRET|TOKEN|PASSWORD|PASSWD)[A-Z0-9_]*=([^\s"'#$()]{8,})\s*$`), group: 1},
+	{kind: "high-entropy-string", re: regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`), check: isHighEntropy},
+}
+
+// SecretFinding describes the secrets of one kind found in a prompt
+type SecretFinding struct {
+	Kind  string
+	Count int
+}
+
+// SecretsError is returned when secrets are found and the configuration blocks sending them
+type SecretsError struct {
+	Findings []SecretFinding
+}
+
+func (e *SecretsError) Error() string {
+	return fmt.Sprintf("potential secrets detected in the changes (%s), refusing to send them to the AI model", formatFindings(e.Findings))
+}
+
+// Redact masks secrets in the text. It returns the redacted text and the kinds of
+// secrets that were found, in the order of the rules that detected them.
+func Redact(text string, config RedactionConfig) (string, []SecretFinding, error) {
+	rules := builtinSecretRules
+	for i, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, secretRule{kind: fmt.Sprintf("custom-pattern-%d", i+1), re: re})
+	}
+
+	counts := make(map[string]int)
+	var kinds []string
+	for _, rule := range rules {
+		var n int
+		text, n = rule.apply(text)
+		if n == 0 {
+			continue
+		}
+		if counts[rule.kind] == 0 {
+			kinds = append(kinds, rule.kind)
+		}
+		counts[rule.kind] += n
+	}
+
+	findings := make([]SecretFinding, 0, len(kinds))
+	for _, kind := range kinds {
+		findings = append(findings, SecretFinding{Kind: kind, Count: counts[kind]})
+	}
+	return text, findings, nil
+}
+
+// apply masks every match of the rule and returns the new text and the number of matches
+func (rule secretRule) apply(text string) (string, int) {
+	var sb strings.Builder
+	count := 0
+	last := 0
+	for _, loc := range rule.re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if rule.group > 0 {
+			start, end = loc[2*rule.group], loc[2*rule.group+1]
+		}
+		if start < last || start < 0 || strings.HasPrefix(text[start:end], "[REDACTED:") {
+			continue
+		}
+		if rule.check != nil && !rule.check(text[start:end]) {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString("[REDACTED:" + rule.kind + "]")
+		last = end
+		count++
+	}
+	if count == 0 {
+		return text, 0
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), count
+}
+
+// isHighEntropy reports whether the token looks like a random secret rather than
+// an identifier or a hex hash
+func isHighEntropy(token string) bool {
+	hasLetter := strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	hasDigit := strings.ContainsAny(token, "0123456789")
+	if !hasLetter || !hasDigit {
+		return false
+	}
+	return shannonEntropy(token) >= 4.5
+}
+
+// shannonEntropy returns the entropy of the string in bits per character
+func shannonEntropy(s string) float64 {
+	freq := make(map[rune]int)
+	for _, r := range s {
+		freq[r]++
+	}
+	entropy := 0.0
+	n := float64(len(s))
+	for _, count := range freq {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// formatFindings formats findings as "kind xN, ..." sorted by kind
+func formatFindings(findings []SecretFinding) string {
+	parts := make([]string, 0, len(findings))
+	for _, f := range findings {
+		parts = append(parts, fmt.Sprintf("%s x%d", f.Kind, f.Count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,108 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BranchNameFields are the values substituted into a branch naming template
+type BranchNameFields struct {
+	Type   string // {type}, e.g. "feature" or "fix"
+	Ticket string // {ticket}, e.g. "JIRA-123"
+	Slug   string // {slug}, e.g. "fix-login-timeout"
+}
+
+var (
+	ticketPattern    = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b|#[0-9]+\b`)
+	nonSlugPattern   = regexp.MustCompile(`[^a-z0-9]+`)
+	separatorPattern = regexp.MustCompile(`[-_.]*/[-_./]*|[-_.]{2,}`)
+)
+
+// ParseTicket returns the first ticket ID in the text, e.g. "JIRA-123" or "#42" (as "42"),
+// or "" if there is none
+func ParseTicket(text string) string {
+	return strings.TrimPrefix(ticketPattern.FindString(text), "#")
+}
+
+// Slugify converts text to lowercase words separated by hyphens
+func Slugify(text string) string {
+	return strings.Trim(nonSlugPattern.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
+
+// ParseBranchFields parses a model answer of the form "type: ...\nslug: ...".
+// The type falls back to the first allowed type and the slug to the whole answer.
+func ParseBranchFields(answer string, types []string) BranchNameFields {
+	var fields BranchNameFields
+	for _, line := range strings.Split(answer, "\n") {
+		key, value, found := strings.Cut(strings.Trim(strings.TrimSpace(line), "`*"), ":")
+		if !found {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "type":
+			fields.Type = Slugify(value)
+		case "slug":
+			fields.Slug = Slugify(value)
+		}
+	}
+
+	if fields.Slug == "" {
+		fields.Slug = Slugify(strings.TrimSpace(answer))
+	}
+
+	if len(types) > 0 {
+		allowed := false
+		for _, t := range types {
+			if fields.Type == t {
+				allowed = true
+			}
+		}
+		if !allowed {
+			fields.Type = types[0]
+		}
+	}
+	return fields
+}
+
+// FormatBranchName renders the naming template, e.g. "{type}/{ticket}-{slug}".
+// Separators left over by empty fields are removed, and the slug is shortened at a
+// word boundary so that the name is at most maxLength characters (if maxLength > 0).
+func FormatBranchName(template string, fields BranchNameFields, maxLength int) string {
+	name := renderBranchTemplate(template, fields)
+
+	if maxLength > 0 && len(name) > maxLength {
+		slug := fields.Slug
+		overflow := len(name) - maxLength
+		if overflow < len(slug) {
+			slug = slug[:len(slug)-overflow]
+			if i := strings.LastIndex(slug, "-"); i > 0 {
+				slug = slug[:i]
+			}
+		} else {
+			slug = ""
+		}
+		fields.Slug = strings.Trim(slug, "-")
+		name = renderBranchTemplate(template, fields)
+		if len(name) > maxLength {
+			name = strings.TrimRight(name[:maxLength], "-_./")
+		}
+	}
+	return name
+}
+
+// renderBranchTemplate substitutes the fields and cleans up empty ones
+func renderBranchTemplate(template string, fields BranchNameFields) string {
+	name := strings.NewReplacer(
+		"{type}", fields.Type,
+		"{ticket}", fields.Ticket,
+		"{slug}", fields.Slug,
+	).Replace(template)
+
+	name = separatorPattern.ReplaceAllStringFunc(name, func(sep string) string {
+		if strings.Contains(sep, "/") {
+			return "/"
+		}
+		return sep[:1]
+	})
+	return strings.Trim(name, "-_./")
+}
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ChangeChunk represents a group of file diffs that fits into a single prompt
+type ChangeChunk struct {
+	Name    string
+	Content string
+}
+
+// SplitChanges splits the detailed changes into chunks of at most maxSize bytes.
+// Files are grouped by directory. Small directories are packed together, large
+// directories are split per file and oversized files are truncated.
+func SplitChanges(changes *Changes, maxSize int) []ChangeChunk {
+	dirs := make(map[string][]string)
+	for file := range changes.Details {
+		dir := path.Dir(file)
+		dirs[dir] = append(dirs[dir], file)
+	}
+
+	dirNames := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+		sort.Strings(dirs[dir])
+	}
+	sort.Strings(dirNames)
+
+	var chunks []ChangeChunk
+	var names []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		chunks = append(chunks, ChangeChunk{Name: strings.Join(names, ", "), Content: current.String()})
+		names = nil
+		current.Reset()
+	}
+
+	for _, dir := range dirNames {
+		var group strings.Builder
+		for _, file := range dirs[dir] {
+			group.WriteString(formatFileDiff(file, changes.Details[file], maxSize))
+		}
+
+		if group.Len() <= maxSize {
+			if current.Len()+group.Len() > maxSize {
+				flush()
+			}
+			names = append(names, dir)
+			current.WriteString(group.String())
+			continue
+		}
+
+		// The directory is too large for one chunk, summarize its files separately
+		flush()
+		for _, file := range dirs[dir] {
+			content := formatFileDiff(file, changes.Details[file], maxSize)
+			if current.Len()+len(content) > maxSize {
+				flush()
+			}
+			names = append(names, file)
+			current.WriteString(content)
+		}
+		flush()
+	}
+	flush()
+
+	return chunks
+}
+
+// formatFileDiff formats the changed lines of a file, truncated to about maxSize bytes
+func formatFileDiff(file string, lines []string, maxSize int) string {
+	var sb strings.Builder
+	sb.WriteString("File: " + file + "\n")
+	for i, line := range lines {
+		if maxSize > 0 && sb.Len()+len(line) > maxSize {
+			sb.WriteString(fmt.Sprintf("... (%d more lines truncated)\n", len(lines)-i))
+			break
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitChanges(t *testing.T) {
+	lines := func(prefix string, n int) []string {
+		var lines []string
+		for i := 0; i < n; i++ {
+			lines = append(lines, fmt.Sprintf("+%s line %02d", prefix, i))
+		}
+		return lines
+	}
+	changes := newChanges()
+	changes.Details = map[string][]string{
+		"a/x.go":     lines("x", 2),
+		"a/y.go":     lines("y", 2),
+		"b/z.go":     lines("z", 2),
+		"big/one.go": lines("one", 8),
+		"big/two.go": lines("two", 8),
+		"huge.go":    lines("huge", 50),
+	}
+
+	const maxSize = 200
+	chunks := SplitChanges(changes, maxSize)
+
+	// Small directories are packed together, the files of a directory that does not
+	// fit get a chunk each and an oversized file is truncated
+	var names []string
+	for _, chunk := range chunks {
+		names = append(names, chunk.Name)
+	}
+	if want := []string{"huge.go", "a, b", "big/one.go", "big/two.go"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("chunk names %q, want %q", names, want)
+	}
+
+	for _, chunk := range chunks {
+		if chunk.Name != "huge.go" && len(chunk.Content) > maxSize {
+			t.Errorf("chunk %s has %d bytes, want at most %d", chunk.Name, len(chunk.Content), maxSize)
+		}
+	}
+	if !strings.Contains(chunks[0].Content, "more lines truncated") || strings.Contains(chunks[0].Content, "huge line 49") {
+		t.Errorf("huge.go is not truncated:\n%s", chunks[0].Content)
+	}
+	for _, file := range []string{"a/x.go", "a/y.go", "b/z.go"} {
+		if !strings.Contains(chunks[1].Content, "File: "+file+"\n") {
+			t.Errorf("chunk %s is missing %s:\n%s", chunks[1].Name, file, chunks[1].Content)
+		}
+	}
+}
+
+func TestFormatFileDiffTruncation(t *testing.T) {
+	got := formatFileDiff("a.go", []string{"+one", "+two", "+three"}, 20)
+	want := "File: a.go\n+one\n+two\n... (1 more lines truncated)\n\n"
+	if got != want {
+		t.Errorf("formatFileDiff = %q, want %q", got, want)
+	}
+
+	if got := formatFileDiff("a.go", []string{"+one"}, 0); got != "File: a.go\n+one\n\n" {
+		t.Errorf("formatFileDiff without a limit = %q", got)
+	}
+}
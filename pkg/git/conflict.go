@@ -0,0 +1,221 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// UnmergedPath is a path with a merge conflict
+type UnmergedPath struct {
+	Path   string
+	Status string // The two letter status, e.g. "UU" (both modified) or "DU" (deleted by us)
+}
+
+// BothModified reports whether both sides changed the content of the file, the only
+// kind of conflict that can be resolved by editing conflict markers
+func (u UnmergedPath) BothModified() bool {
+	return u.Status == "UU" || u.Status == "AA"
+}
+
+// Description returns git's description of the conflict, e.g. "deleted by us"
+func (u UnmergedPath) Description() string {
+	switch u.Status {
+	case "DD":
+		return "both deleted"
+	case "AU":
+		return "added by us"
+	case "UD":
+		return "deleted by them"
+	case "UA":
+		return "added by them"
+	case "DU":
+		return "deleted by us"
+	case "AA":
+		return "both added"
+	default:
+		return "both modified"
+	}
+}
+
+// ConflictHunk is a block between conflict markers
+type ConflictHunk struct {
+	Start       int      // Index of the "<<<<<<<" line
+	End         int      // Index of the ">>>>>>>" line
+	Ours        []string // Lines of our side
+	Base        []string // Lines of the common ancestor, nil if unknown
+	Theirs      []string // Lines of their side
+	OursLabel   string   // The label after "<<<<<<<", e.g. "HEAD"
+	TheirsLabel string   // The label after ">>>>>>>", e.g. "feature"
+}
+
+// GetUnmergedPaths returns the paths with merge conflicts, parsed from git status
+func GetUnmergedPaths() ([]UnmergedPath, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=no")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+
+	var paths []UnmergedPath
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status := entry[:2]
+		// Renames and copies are followed by the original path
+		if status[0] == 'R' || status[0] == 'C' {
+			i++
+		}
+		if status[0] == 'U' || status[1] == 'U' || status == "AA" || status == "DD" {
+			paths = append(paths, UnmergedPath{Path: entry[3:], Status: status})
+		}
+	}
+	return paths, nil
+}
+
+// GetStageContent returns the content of a path in an index stage: 1 for the
+// common ancestor, 2 for ours and 3 for theirs. It reports false if the stage
+// does not exist, e.g. the base of a file both sides added.
+func GetStageContent(stage int, path string) (string, bool, error) {
+	cmd := exec.Command("git", "ls-files", "--stage", "--", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", false, gitError(err)
+	}
+	found := false
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[2] == fmt.Sprint(stage) {
+			found = true
+		}
+	}
+	if !found {
+		return "", false, nil
+	}
+
+	cmd = exec.Command("git", "show", fmt.Sprintf(":%d:%s", stage, path))
+	output, err = cmd.Output()
+	if err != nil {
+		return "", false, gitError(err)
+	}
+	return string(output), true, nil
+}
+
+// GetIndexMode returns the permissions git records for a path in the index, from our
+// side of a conflict if there is one: 0o755 for executable files and 0o644 otherwise
+func GetIndexMode(path string) (os.FileMode, error) {
+	cmd := exec.Command("git", "ls-files", "--stage", "--", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, gitError(err)
+	}
+	mode := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && (mode == "" || fields[2] == "2") {
+			mode = fields[0]
+		}
+	}
+	switch mode {
+	case "":
+		return 0, fmt.Errorf("%s is not in the index", path)
+	case "100755":
+		return 0o755, nil
+	default:
+		return 0o644, nil
+	}
+}
+
+// ParseConflicts returns the conflict hunks in the lines of a file. Both the merge
+// and the diff3 conflict styles are supported; with the merge style, Base is nil.
+func ParseConflicts(lines []string) []ConflictHunk {
+	var hunks []ConflictHunk
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "<<<<<<<") {
+			continue
+		}
+		hunk := ConflictHunk{Start: i, OursLabel: markerLabel(lines[i])}
+		section := &hunk.Ours
+		complete := false
+		for j := i + 1; j < len(lines); j++ {
+			line := lines[j]
+			switch {
+			case strings.HasPrefix(line, "|||||||") && section == &hunk.Ours:
+				hunk.Base = []string{}
+				section = &hunk.Base
+			case strings.HasPrefix(line, "=======") && section != &hunk.Theirs:
+				section = &hunk.Theirs
+			case strings.HasPrefix(line, ">>>>>>>") && section == &hunk.Theirs:
+				hunk.End = j
+				hunk.TheirsLabel = markerLabel(line)
+				complete = true
+			default:
+				*section = append(*section, line)
+			}
+			if complete {
+				break
+			}
+		}
+		if !complete {
+			break
+		}
+		hunks = append(hunks, hunk)
+		i = hunk.End
+	}
+	return hunks
+}
+
+// markerLabel returns the label after a conflict marker
+func markerLabel(line string) string {
+	return strings.TrimSpace(strings.TrimLeft(line, "<>|"))
+}
+
+// GetConflictBases fills in the base of conflict hunks parsed from the merge
+// conflict style by merging the index stages again in the diff3 style. Hunks
+// whose sides were edited since the merge keep a nil base.
+func GetConflictBases(path string, hunks []ConflictHunk) error {
+	var files [3]string
+	for i, stage := range []int{2, 1, 3} {
+		content, _, err := GetStageContent(stage, path)
+		if err != nil {
+			return err
+		}
+		temp, err := os.CreateTemp("", "ai-git-merge-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(temp.Name())
+		if _, err := temp.WriteString(content); err != nil {
+			temp.Close()
+			return err
+		}
+		temp.Close()
+		files[i] = temp.Name()
+	}
+
+	// git merge-file exits with the number of conflicts, or 255 on errors
+	cmd := exec.Command("git", "merge-file", "-p", "--diff3", files[0], files[1], files[2])
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() < 128) {
+		return gitError(err)
+	}
+
+	merged := ParseConflicts(strings.Split(string(output), "\n"))
+	for i := range hunks {
+		if hunks[i].Base != nil {
+			continue
+		}
+		for _, candidate := range merged {
+			if slices.Equal(candidate.Ours, hunks[i].Ours) && slices.Equal(candidate.Theirs, hunks[i].Theirs) {
+				hunks[i].Base = candidate.Base
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestGetIndexMode(t *testing.T) {
+	newTestRepo(t)
+	writeTestFile(t, "run.sh", "echo base\n")
+	writeTestFile(t, "notes.txt", "base\n")
+	if err := os.Chmod("run.sh", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, "add", ".")
+	runTestGit(t, "commit", "-q", "-m", "base")
+
+	// Both sides change run.sh, so it is in the index as stages 1 to 3
+	runTestGit(t, "checkout", "-q", "-b", "other")
+	writeTestFile(t, "run.sh", "echo other\n")
+	runTestGit(t, "commit", "-q", "-am", "other")
+	runTestGit(t, "checkout", "-q", "main")
+	writeTestFile(t, "run.sh", "echo main\n")
+	runTestGit(t, "commit", "-q", "-am", "main")
+	if err := exec.Command("git", "merge", "other").Run(); err == nil {
+		t.Fatal("git merge succeeded, want a conflict")
+	}
+
+	tests := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{"run.sh", 0o755},
+		{"notes.txt", 0o644},
+	}
+	for _, test := range tests {
+		mode, err := GetIndexMode(test.path)
+		if err != nil {
+			t.Fatalf("GetIndexMode(%q): %v", test.path, err)
+		}
+		if mode != test.mode {
+			t.Errorf("GetIndexMode(%q) = %o, want %o", test.path, mode, test.mode)
+		}
+	}
+	if _, err := GetIndexMode("missing.txt"); err == nil {
+		t.Error("GetIndexMode(\"missing.txt\") succeeded, want an error")
+	}
+}
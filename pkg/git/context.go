@@ -0,0 +1,108 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// RepoContext describes the state of the repository for questions about it
+type RepoContext struct {
+	Branch        string // The checked out branch, or "HEAD" if detached
+	Upstream      string // The upstream of the branch, e.g. "origin/main", if any
+	Operation     string // An operation in progress, e.g. "rebase" or "merge"
+	Status        string // git status --short --branch
+	Remotes       string // git remote -v
+	RecentCommits string // The last commits, one per line
+	Stashes       string // git stash list
+	Excluded      int    // Number of files left out of Status by Exclude
+}
+
+// operationFiles are files in the git directory that indicate an operation in progress
+var operationFiles = []struct {
+	path      string
+	operation string
+}{
+	{"rebase-merge", "rebase"},
+	{"rebase-apply", "rebase or am"},
+	{"MERGE_HEAD", "merge"},
+	{"CHERRY_PICK_HEAD", "cherry-pick"},
+	{"REVERT_HEAD", "revert"},
+	{"BISECT_LOG", "bisect"},
+}
+
+// GetRepoContext collects the branch, status, remotes, recent commits and stashes.
+// Missing information, like the upstream of a new branch, is left empty.
+func GetRepoContext() (*RepoContext, error) {
+	branch, err := GetCurrentBranch()
+	if err != nil {
+		// A repository without commits has no HEAD to describe
+		branch = strings.TrimSpace(gitOutput("symbolic-ref", "--short", "HEAD"))
+	}
+
+	// Paths relative to the top level, like those matched by the policy
+	status, err := exec.Command("git", "-c", "status.relativePaths=false", "status", "--short", "--branch").Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+
+	context := &RepoContext{
+		Branch:        branch,
+		Upstream:      strings.TrimSpace(gitOutput("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")),
+		Status:        strings.TrimRight(string(status), "\n"),
+		Remotes:       strings.TrimRight(gitOutput("remote", "-v"), "\n"),
+		RecentCommits: strings.TrimRight(gitOutput("log", "-10", "--format=%h %ad %s", "--date=short"), "\n"),
+		Stashes:       strings.TrimRight(gitOutput("stash", "list"), "\n"),
+	}
+
+	for _, file := range operationFiles {
+		path := strings.TrimSpace(gitOutput("rev-parse", "--git-path", file.path))
+		if _, err := os.Stat(path); path != "" && err == nil {
+			context.Operation = file.operation
+			break
+		}
+	}
+	return context, nil
+}
+
+// Exclude removes the status lines of files matched by the rules, so that they do not
+// reach prompts, not even by name. The number of removed files is added to Excluded.
+func (context *RepoContext) Exclude(rules IgnoreRules) {
+	if len(rules) == 0 || context.Status == "" {
+		return
+	}
+
+	var kept []string
+	for _, line := range strings.Split(context.Status, "\n") {
+		if !strings.HasPrefix(line, "## ") && len(line) > 3 && statusPathsMatch(line[3:], rules) {
+			context.Excluded++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	context.Status = strings.Join(kept, "\n")
+}
+
+// statusPathsMatch reports whether the path of a git status --short line, or one of
+// the paths of a rename, is matched by the rules
+func statusPathsMatch(paths string, rules IgnoreRules) bool {
+	for _, path := range strings.Split(paths, " -> ") {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		if rules.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitOutput returns the output of a git command, or "" if it fails
+func gitOutput(args ...string) string {
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
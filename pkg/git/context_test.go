@@ -0,0 +1,16 @@
+package git
+
+import "testing"
+
+func TestRepoContextExclude(t *testing.T) {
+	context := &RepoContext{Status: "## main...origin/main\n M main.go\n M secrets/api.key\n?? secrets/\nR  old.go -> config/prod.pem\n?? \"secrets/a b.txt\""}
+	context.Exclude(ParseIgnoreRules([]string{"secrets/", "*.pem"}))
+
+	want := "## main...origin/main\n M main.go"
+	if context.Status != want {
+		t.Errorf("Status = %q, want %q", context.Status, want)
+	}
+	if context.Excluded != 4 {
+		t.Errorf("Excluded = %d, want 4", context.Excluded)
+	}
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ConventionalCommit is a commit message following the Conventional Commits format,
+// e.g. "feat(api)!: remove the v1 endpoints"
+type ConventionalCommit struct {
+	Type         string // e.g. "feat" or "fix", always lowercase
+	Scope        string // e.g. "api", empty if there is none
+	Description  string // The subject after the colon
+	Breaking     bool   // Marked with "!" or a BREAKING CHANGE footer
+	BreakingNote string // The text of the BREAKING CHANGE footer, if any
+}
+
+var (
+	conventionalPattern   = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()]*)\))?(!)?:\s+(.+)$`)
+	breakingFooterPattern = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s*(.*)$`)
+	referencePattern      = regexp.MustCompile(`(?:^|[\s(\[,])([#!][0-9]+)\b`)
+	// referenceStripPattern also matches a closing keyword in front of a reference
+	referenceStripPattern   = regexp.MustCompile(`(?i)(?:[\s,]*\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?|refs?|see)\s+|^|[\s(\[,])[#!][0-9]+\b`)
+	emptyParenthesesPattern = regexp.MustCompile(`\(\s*(?:,\s*)*\)`)
+	whitespacePattern       = regexp.MustCompile(`\s+`)
+)
+
+// ParseConventionalCommit parses a commit message in the Conventional Commits format.
+// It returns false if the subject does not follow the format.
+func ParseConventionalCommit(subject, body string) (ConventionalCommit, bool) {
+	match := conventionalPattern.FindStringSubmatch(strings.TrimSpace(subject))
+	if match == nil {
+		return ConventionalCommit{}, false
+	}
+
+	commit := ConventionalCommit{
+		Type:        strings.ToLower(match[1]),
+		Scope:       strings.TrimSpace(match[2]),
+		Description: strings.TrimSpace(match[4]),
+		Breaking:    match[3] == "!",
+	}
+	if footer := breakingFooterPattern.FindStringSubmatch(body); footer != nil {
+		commit.Breaking = true
+		commit.BreakingNote = strings.TrimSpace(footer[1])
+	}
+	return commit, true
+}
+
+// ParseReferences returns the pull request and issue numbers mentioned in a commit
+// message, e.g. "#123" for GitHub or "!45" for GitLab merge requests, without duplicates
+func ParseReferences(message string) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, match := range referencePattern.FindAllStringSubmatch(message, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			refs = append(refs, match[1])
+		}
+	}
+	return refs
+}
+
+// StripReferences removes pull request and issue numbers from a text, along with
+// closing keywords and the parentheses left empty, e.g. "fix login (#123)" and
+// "fix login, closes #123" become "fix login"
+func StripReferences(text string) string {
+	text = referenceStripPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if strings.HasPrefix(match, "(") || strings.HasPrefix(match, "[") {
+			return match[:1]
+		}
+		return ""
+	})
+	text = emptyParenthesesPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+}
@@ -0,0 +1,196 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DiffLine is a line of a hunk with its line numbers in the old and the new file.
+// The line number on the side the line does not exist on is 0.
+type DiffLine struct {
+	Kind    byte   `json:"kind"` // '+', '-', ' ' or '\\' for "\ No newline at end of file"
+	OldLine int    `json:"old_line"`
+	NewLine int    `json:"new_line"`
+	Text    string `json:"text"`
+}
+
+// Hunk is a block of changes in a file
+type Hunk struct {
+	Header   string     `json:"header"` // The "@@ -1,3 +1,4 @@ context" line
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// FileDiff is the diff of a single file
+type FileDiff struct {
+	Path    string     `json:"path"`     // The path in the new tree, or the old path for deletions
+	OldPath string     `json:"old_path"` // Differs from Path for renames
+	Status  FileStatus `json:"status"`
+	Binary  bool       `json:"binary"`
+	Header  []string   `json:"header"` // The lines from "diff --git" up to the first hunk
+	Hunks   []Hunk     `json:"hunks"`
+}
+
+// hunkHeaderPattern matches "@@ -old[,count] +new[,count] @@"
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// GetStagedDiff returns the structured diff of the staged changes
+func GetStagedDiff(pathspecs ...string) ([]FileDiff, error) {
+	args := withPathspecs([]string{"diff", "--cached", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"}, pathspecs)
+	cmd := exec.Command("git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	return ParseDiff(string(output)), nil
+}
+
+// ParseDiff parses the output of git diff into files, hunks and numbered lines
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+	oldLine, newLine := 0, 0
+
+	lines := strings.Split(diff, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			files = append(files, FileDiff{Status: Modified})
+			file = &files[len(files)-1]
+			hunk = nil
+			if parts := strings.SplitN(strings.TrimPrefix(line, "diff --git a/"), " b/", 2); len(parts) == 2 {
+				file.OldPath, file.Path = parts[0], parts[1]
+			}
+			file.Header = append(file.Header, line)
+			continue
+		}
+		if file == nil {
+			continue
+		}
+
+		if hunk == nil && !strings.HasPrefix(line, "@@") {
+			file.Header = append(file.Header, line)
+			switch {
+			case strings.HasPrefix(line, "new file mode"):
+				file.Status = Added
+			case strings.HasPrefix(line, "deleted file mode"):
+				file.Status = Deleted
+			case strings.HasPrefix(line, "rename from "):
+				file.OldPath = strings.TrimPrefix(line, "rename from ")
+			case strings.HasPrefix(line, "rename to "):
+				file.Path = strings.TrimPrefix(line, "rename to ")
+			case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+				file.Binary = true
+			}
+			continue
+		}
+
+		if match := hunkHeaderPattern.FindStringSubmatch(line); match != nil {
+			file.Hunks = append(file.Hunks, Hunk{
+				Header:   line,
+				OldStart: atoiDefault(match[1], 0),
+				OldLines: atoiDefault(match[2], 1),
+				NewStart: atoiDefault(match[3], 0),
+				NewLines: atoiDefault(match[4], 1),
+			})
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			continue
+		}
+		if hunk == nil || line == "" {
+			continue
+		}
+
+		switch line[0] {
+		case '+':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: '+', NewLine: newLine, Text: line[1:]})
+			newLine++
+		case '-':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: '-', OldLine: oldLine, Text: line[1:]})
+			oldLine++
+		case ' ':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: ' ', OldLine: oldLine, NewLine: newLine, Text: line[1:]})
+			oldLine++
+			newLine++
+		case '\\':
+			// "\ No newline at end of file" belongs to the previous line
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: '\\', Text: line[1:]})
+		}
+	}
+	return files
+}
+
+// FilterFileDiffs drops files matched by the ignore rules or marked as generated
+// in .gitattributes, and returns the paths of the dropped files
+func FilterFileDiffs(files []FileDiff, rules IgnoreRules) ([]FileDiff, []string, error) {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	generated, err := GetGeneratedFiles(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var kept []FileDiff
+	var omitted []string
+	for _, file := range files {
+		if generated[file.Path] || rules.Match(file.Path) {
+			omitted = append(omitted, file.Path)
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, omitted, nil
+}
+
+// FormatFileDiff renders a file diff with the line number of each line in the new
+// file, or in the old file for removed lines, so that a model can refer to lines
+func FormatFileDiff(file FileDiff) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "File: %s (%s)\n", file.Path, file.Status)
+	if file.OldPath != "" && file.OldPath != file.Path {
+		fmt.Fprintf(&sb, "Renamed from: %s\n", file.OldPath)
+	}
+	if file.Binary {
+		sb.WriteString("Binary file\n")
+		return sb.String()
+	}
+
+	for _, hunk := range file.Hunks {
+		sb.WriteString(hunk.Header + "\n")
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case '+':
+				fmt.Fprintf(&sb, "%5d + %s\n", line.NewLine, line.Text)
+			case '-':
+				fmt.Fprintf(&sb, "%5d - %s\n", line.OldLine, line.Text)
+			case ' ':
+				fmt.Fprintf(&sb, "%5d   %s\n", line.NewLine, line.Text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// atoiDefault parses a number, returning def for an empty string
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
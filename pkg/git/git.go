@@ -1,7 +1,10 @@
 package git
 
 import (
+	"fmt"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -16,11 +19,14 @@ const (
 
 // Changes represents git changes in the repository
 type Changes struct {
-	Modified []string            `json:"modified"`
-	Added    []string            `json:"added"`
-	Deleted  []string            `json:"deleted"`
-	Unknown  []string            `json:"unknown"`
-	Details  map[string][]string `json:"details"`
+	Modified    []string            `json:"modified"`
+	Added       []string            `json:"added"`
+	Deleted     []string            `json:"deleted"`
+	Unknown     []string            `json:"unknown"`
+	Omitted     []string            `json:"omitted"`
+	Excluded    int                 `json:"excluded"`
+	Details     map[string][]string `json:"details"`
+	RenamedFrom map[string]string   `json:"renamed_from"` // The path each renamed or copied file comes from
 }
 
 // GetDiff gets the git diff information for the current working directory
@@ -43,35 +49,31 @@ func GetStatus() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// GetChanges gets detailed information about changes in the git repository
-func GetChanges() (*Changes, error) {
+// GetChanges gets detailed information about changes in the git repository.
+// If pathspecs are given, only changes matching them are included.
+func GetChanges(pathspecs ...string) (*Changes, error) {
 	// Get git diff
-	diffCmd := exec.Command("git", "diff", "HEAD")
+	diffCmd := exec.Command("git", withPathspecs([]string{"diff", "HEAD"}, pathspecs)...)
 	diffOutput, err := diffCmd.Output()
 	if err != nil {
 		return nil, err
 	}
 
 	// Get git status
-	statusCmd := exec.Command("git", "status", "--porcelain")
+	statusCmd := exec.Command("git", withPathspecs([]string{"status", "--porcelain"}, pathspecs)...)
 	statusOutput, err := statusCmd.Output()
 	if err != nil {
 		return nil, err
 	}
 
 	// Initialize changes
-	changes := &Changes{
-		Modified: make([]string, 0),
-		Added:    make([]string, 0),
-		Deleted:  make([]string, 0),
-		Unknown:  make([]string, 0),
-		Details:  make(map[string][]string),
-	}
+	changes := newChanges()
 
 	// Process status output to categorize files
-	statusLines := strings.Split(strings.TrimSpace(string(statusOutput)), "\n")
+	// Only trim the trailing newline, the leading space is part of the status code
+	statusLines := strings.Split(strings.TrimRight(string(statusOutput), "\n"), "\n")
 	for _, line := range statusLines {
-		if line == "" {
+		if len(line) < 4 {
 			continue
 		}
 
@@ -93,10 +95,285 @@ func GetChanges() (*Changes, error) {
 	}
 
 	// Process diff output to get detailed changes
-	diffLines := strings.Split(string(diffOutput), "\n")
+	parseDiffDetails(string(diffOutput), changes)
+
+	// Omit the diff of generated, vendored and ignored files
+	if err := omitIgnored(changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// EmptyTree is the hash of the empty tree, used as the base of root commits
+const EmptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// GetDiffChanges gets detailed information about the changes reported by
+// git diff with the given arguments, e.g. two revisions or "--cached" and a revision
+func GetDiffChanges(args ...string) (*Changes, error) {
+	statusCmd := exec.Command("git", append([]string{"diff", "--name-status"}, args...)...)
+	statusOutput, err := statusCmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	diffCmd := exec.Command("git", append([]string{"diff"}, args...)...)
+	diffOutput, err := diffCmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := newChanges()
+
+	// Each line is a status letter followed by tab separated paths. Renames and
+	// copies list the old and the new path and are reported as modified.
+	for _, line := range strings.Split(strings.TrimRight(string(statusOutput), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		file := fields[len(fields)-1]
+		if len(fields) == 3 {
+			changes.RenamedFrom[file] = fields[1]
+		}
+		switch fields[0][0] {
+		case 'A':
+			changes.Added = append(changes.Added, file)
+		case 'D':
+			changes.Deleted = append(changes.Deleted, file)
+		default:
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+
+	parseDiffDetails(string(diffOutput), changes)
+
+	if err := omitIgnored(changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// GetStagedChanges gets detailed information about the changes in the index,
+// which is what the next commit will contain
+func GetStagedChanges() (*Changes, error) {
+	base := "HEAD"
+	if !RevisionExists(base) {
+		base = EmptyTree
+	}
+	return GetDiffChanges("--cached", base)
+}
+
+// GetCommitChanges gets the changes that git commit will record: the staged changes,
+// the working tree changes of tracked files if all is set, or the working tree changes
+// matching the pathspecs. With include, those are added to the staged changes instead
+// of replacing them, as git commit --include does.
+func GetCommitChanges(all, include bool, pathspecs ...string) (*Changes, error) {
+	base := "HEAD"
+	if !RevisionExists(base) {
+		base = EmptyTree
+	}
+
+	switch {
+	case include && len(pathspecs) > 0:
+		staged, err := GetDiffChanges("--cached", base)
+		if err != nil {
+			return nil, err
+		}
+		paths, err := changedPaths(withPathspecs([]string{base}, pathspecs))
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return staged, nil
+		}
+		updated, err := GetDiffChanges(withPathspecs([]string{base}, literalPathspecs(paths))...)
+		if err != nil {
+			return nil, err
+		}
+		return mergeChanges(staged, updated, paths), nil
+	case all || len(pathspecs) > 0:
+		return GetDiffChanges(withPathspecs([]string{base}, pathspecs)...)
+	default:
+		return GetDiffChanges("--cached", base)
+	}
+}
+
+// GetAmendChanges gets the changes of the HEAD commit combined with the changes
+// that will be added to it when amending. Those are the staged changes, or the
+// working tree changes if all is set or pathspecs are given. Pathspecs only limit
+// the new changes, everything already in HEAD stays in the amended commit.
+func GetAmendChanges(all bool, pathspecs ...string) (*Changes, error) {
+	base := "HEAD~1"
+	if !RevisionExists(base) {
+		base = EmptyTree
+	}
+
+	committed, err := GetDiffChanges(base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	// Without "--cached" the new content is read from the working tree
+	var source []string
+	if !all && len(pathspecs) == 0 {
+		source = []string{"--cached"}
+	}
+	paths, err := changedPaths(withPathspecs(append(source, "HEAD"), pathspecs))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return committed, nil
+	}
+
+	// Files changed again are described from before HEAD to their new content
+	updated, err := GetDiffChanges(withPathspecs(append(source, base), literalPathspecs(paths))...)
+	if err != nil {
+		return nil, err
+	}
+	return mergeChanges(committed, updated, paths), nil
+}
+
+// changedPaths lists the paths reported by git diff with the given arguments. Renames
+// are listed as the old and the new path.
+func changedPaths(args []string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"diff", "--name-only", "--no-renames", "-z"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// literalPathspecs turns paths into pathspecs that match exactly these paths
+func literalPathspecs(paths []string) []string {
+	literal := make([]string, len(paths))
+	for i, path := range paths {
+		literal[i] = ":(literal)" + path
+	}
+	return literal
+}
+
+// mergeChanges returns the changes of base with the given paths replaced by the changes in updated
+func mergeChanges(base, updated *Changes, paths []string) *Changes {
+	replaced := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		replaced[path] = true
+	}
+	merge := func(from, to []string) []string {
+		merged := make([]string, 0, len(from)+len(to))
+		for _, file := range from {
+			if !replaced[file] {
+				merged = append(merged, file)
+			}
+		}
+		return append(merged, to...)
+	}
+
+	merged := newChanges()
+	merged.Modified = merge(base.Modified, updated.Modified)
+	merged.Added = merge(base.Added, updated.Added)
+	merged.Deleted = merge(base.Deleted, updated.Deleted)
+	merged.Unknown = merge(base.Unknown, updated.Unknown)
+	merged.Omitted = merge(base.Omitted, updated.Omitted)
+	merged.Excluded = base.Excluded + updated.Excluded
+	for file, lines := range base.Details {
+		if !replaced[file] {
+			merged.Details[file] = lines
+		}
+	}
+	for file, lines := range updated.Details {
+		merged.Details[file] = lines
+	}
+	for file, from := range base.RenamedFrom {
+		if !replaced[file] {
+			merged.RenamedFrom[file] = from
+		}
+	}
+	for file, from := range updated.RenamedFrom {
+		merged.RenamedFrom[file] = from
+	}
+	return merged
+}
+
+// GetCommitMessage returns the full message of a commit
+func GetCommitMessage(rev string) (string, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%B", rev)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetHooksDir returns the absolute path of the hooks directory, honoring core.hooksPath
+func GetHooksDir() (string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	dir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// RevisionExists reports whether the revision resolves to a commit
+func RevisionExists(rev string) bool {
+	cmd := exec.Command("git", "rev-parse", "--quiet", "--verify", rev+"^{commit}")
+	return cmd.Run() == nil
+}
+
+// GetBuiltinCommands returns the commands that come with git, without aliases and
+// git-<name> programs found on the PATH
+func GetBuiltinCommands() (map[string]bool, error) {
+	cmd := exec.Command("git", "--list-cmds=main")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	commands := make(map[string]bool)
+	for _, name := range strings.Fields(string(output)) {
+		commands[name] = true
+	}
+	return commands, nil
+}
+
+// newChanges returns an empty Changes
+func newChanges() *Changes {
+	return &Changes{
+		Modified:    make([]string, 0),
+		Added:       make([]string, 0),
+		Deleted:     make([]string, 0),
+		Unknown:     make([]string, 0),
+		Omitted:     make([]string, 0),
+		Details:     make(map[string][]string),
+		RenamedFrom: make(map[string]string),
+	}
+}
+
+// parseDiffDetails collects the added and removed lines of each file in a unified diff
+func parseDiffDetails(diff string, changes *Changes) {
 	var currentFile string
 
-	for _, line := range diffLines {
+	for _, line := range strings.Split(diff, "\n") {
 		if strings.HasPrefix(line, "diff --git") {
 			parts := strings.Split(line, " b/")
 			if len(parts) > 1 {
@@ -110,20 +387,56 @@ func GetChanges() (*Changes, error) {
 			}
 		}
 	}
+}
 
-	return changes, nil
+// omitIgnored omits the diff of generated, vendored and ignored files
+func omitIgnored(changes *Changes) error {
+	rules, err := LoadIgnoreRules()
+	if err != nil {
+		return err
+	}
+	return OmitIgnoredDiffs(changes, rules)
+}
+
+// withPathspecs appends the pathspecs to the git arguments, separated by "--"
+func withPathspecs(args []string, pathspecs []string) []string {
+	if len(pathspecs) == 0 {
+		return args
+	}
+	return append(append(args, "--"), pathspecs...)
 }
 
 // FormatChangesForPrompt converts the Changes structure to a formatted string for use in AI prompts
 func FormatChangesForPrompt(changes *Changes) string {
 	var sb strings.Builder
 
+	sb.WriteString(FormatFileLists(changes))
+
+	// Add detailed changes
+	if len(changes.Details) > 0 {
+		sb.WriteString("Detailed Changes:\n\n")
+		for _, file := range DetailOrder(changes) {
+			sb.WriteString("File: " + file + "\n")
+			for _, line := range changes.Details[file] {
+				sb.WriteString(line + "\n")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// FormatFileLists formats the lists of changed files for a prompt, without their diff
+func FormatFileLists(changes *Changes) string {
+	var sb strings.Builder
+
 	sb.WriteString("Git Changes Summary:\n\n")
 
 	// Add summary information
 	if len(changes.Modified) > 0 {
 		sb.WriteString("Modified files:\n")
-		for _, file := range changes.Modified {
+		for _, file := range sortedFiles(changes.Modified) {
 			sb.WriteString("- " + file + "\n")
 		}
 		sb.WriteString("\n")
@@ -131,7 +444,7 @@ func FormatChangesForPrompt(changes *Changes) string {
 
 	if len(changes.Added) > 0 {
 		sb.WriteString("Added files:\n")
-		for _, file := range changes.Added {
+		for _, file := range sortedFiles(changes.Added) {
 			sb.WriteString("- " + file + "\n")
 		}
 		sb.WriteString("\n")
@@ -139,7 +452,7 @@ func FormatChangesForPrompt(changes *Changes) string {
 
 	if len(changes.Deleted) > 0 {
 		sb.WriteString("Deleted files:\n")
-		for _, file := range changes.Deleted {
+		for _, file := range sortedFiles(changes.Deleted) {
 			sb.WriteString("- " + file + "\n")
 		}
 		sb.WriteString("\n")
@@ -147,23 +460,100 @@ func FormatChangesForPrompt(changes *Changes) string {
 
 	if len(changes.Unknown) > 0 {
 		sb.WriteString("Unknown files:\n")
-		for _, file := range changes.Unknown {
+		for _, file := range sortedFiles(changes.Unknown) {
 			sb.WriteString("- " + file + "\n")
 		}
 		sb.WriteString("\n")
 	}
 
-	// Add detailed changes
-	if len(changes.Details) > 0 {
-		sb.WriteString("Detailed Changes:\n\n")
-		for file, lines := range changes.Details {
-			sb.WriteString("File: " + file + "\n")
-			for _, line := range lines {
-				sb.WriteString(line + "\n")
-			}
-			sb.WriteString("\n")
+	if len(changes.Omitted) > 0 {
+		sb.WriteString("Files with omitted diff (generated, vendored or ignored):\n")
+		for _, file := range sortedFiles(changes.Omitted) {
+			sb.WriteString("- " + file + "\n")
 		}
+		sb.WriteString("\n")
+	}
+
+	if changes.Excluded > 0 {
+		sb.WriteString(fmt.Sprintf("%d file(s) not shown, excluded by the repository policy\n\n", changes.Excluded))
 	}
 
 	return sb.String()
 }
+
+// ExcludeChanges removes every file matched by the rules from the changes, including
+// its name. A renamed or copied file is removed when the path it comes from matches.
+// The number of removed files is added to changes.Excluded.
+func ExcludeChanges(changes *Changes, rules IgnoreRules) {
+	if len(rules) == 0 {
+		return
+	}
+
+	match := func(file string) bool {
+		from, renamed := changes.RenamedFrom[file]
+		return rules.Match(file) || (renamed && rules.Match(from))
+	}
+	excluded := make(map[string]bool)
+	filter := func(files []string) []string {
+		kept := make([]string, 0, len(files))
+		for _, file := range files {
+			if match(file) {
+				excluded[file] = true
+				continue
+			}
+			kept = append(kept, file)
+		}
+		return kept
+	}
+
+	changes.Modified = filter(changes.Modified)
+	changes.Added = filter(changes.Added)
+	changes.Deleted = filter(changes.Deleted)
+	changes.Unknown = filter(changes.Unknown)
+	changes.Omitted = filter(changes.Omitted)
+	for file := range changes.Details {
+		if match(file) {
+			excluded[file] = true
+			delete(changes.Details, file)
+		}
+	}
+	for file := range excluded {
+		delete(changes.RenamedFrom, file)
+	}
+
+	changes.Excluded += len(excluded)
+}
+
+// DetailOrder returns the files in changes.Details in a stable order: grouped by
+// status category (modified, added, deleted, unknown, then any other file), and
+// sorted by path within each category
+func DetailOrder(changes *Changes) []string {
+	order := make([]string, 0, len(changes.Details))
+	seen := make(map[string]bool, len(changes.Details))
+
+	for _, category := range [][]string{changes.Modified, changes.Added, changes.Deleted, changes.Unknown} {
+		for _, file := range sortedFiles(category) {
+			if _, ok := changes.Details[file]; ok && !seen[file] {
+				order = append(order, file)
+				seen[file] = true
+			}
+		}
+	}
+
+	var rest []string
+	for file := range changes.Details {
+		if !seen[file] {
+			rest = append(rest, file)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(order, rest...)
+}
+
+// sortedFiles returns a sorted copy of the file list
+func sortedFiles(files []string) []string {
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,271 @@
+package git
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files with the current output
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// newTestRepo creates an empty repository and makes it the working directory
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	runTestGit(t, "init", "-q", "-b", "main")
+	runTestGit(t, "config", "user.name", "Test")
+	runTestGit(t, "config", "user.email", "test@example.com")
+	return dir
+}
+
+// runTestGit runs git in the working directory and fails the test on error
+func runTestGit(t *testing.T, args ...string) {
+	t.Helper()
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+// writeTestFile writes a file relative to the working directory
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAmendChanges(t *testing.T) {
+	newTestRepo(t)
+	writeTestFile(t, "a.txt", "a\n")
+	writeTestFile(t, "b.txt", "b\n")
+	runTestGit(t, "add", ".")
+	runTestGit(t, "commit", "-q", "-m", "base")
+
+	// HEAD changes a.txt and adds c.txt
+	writeTestFile(t, "a.txt", "a\na2\n")
+	writeTestFile(t, "c.txt", "c\n")
+	runTestGit(t, "add", ".")
+	runTestGit(t, "commit", "-q", "-m", "head")
+
+	// b.txt is staged, c.txt is changed again in the working tree only
+	writeTestFile(t, "b.txt", "b\nb2\n")
+	runTestGit(t, "add", "b.txt")
+	writeTestFile(t, "c.txt", "c\nc2\n")
+
+	tests := []struct {
+		name      string
+		all       bool
+		pathspecs []string
+		modified  []string
+		added     []string
+		details   map[string][]string
+	}{
+		{
+			name:     "staged",
+			modified: []string{"a.txt", "b.txt"},
+			added:    []string{"c.txt"},
+			details:  map[string][]string{"a.txt": {"+a2"}, "b.txt": {"+b2"}, "c.txt": {"+c"}},
+		},
+		{
+			name:     "all",
+			all:      true,
+			modified: []string{"a.txt", "b.txt"},
+			added:    []string{"c.txt"},
+			details:  map[string][]string{"a.txt": {"+a2"}, "b.txt": {"+b2"}, "c.txt": {"+c", "+c2"}},
+		},
+		{
+			// The pathspec limits the new changes, a.txt is still part of the amended commit
+			name:      "pathspec",
+			pathspecs: []string{"c.txt"},
+			modified:  []string{"a.txt"},
+			added:     []string{"c.txt"},
+			details:   map[string][]string{"a.txt": {"+a2"}, "c.txt": {"+c", "+c2"}},
+		},
+	}
+
+	for _, test := range tests {
+		changes, err := GetAmendChanges(test.all, test.pathspecs...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		sort.Strings(changes.Modified)
+		if !reflect.DeepEqual(changes.Modified, test.modified) || !reflect.DeepEqual(changes.Added, test.added) {
+			t.Errorf("%s: modified %q, added %q, want %q, %q", test.name, changes.Modified, changes.Added, test.modified, test.added)
+		}
+		if !reflect.DeepEqual(changes.Details, test.details) {
+			t.Errorf("%s: details %q, want %q", test.name, changes.Details, test.details)
+		}
+	}
+}
+
+func TestGetCommitChanges(t *testing.T) {
+	newTestRepo(t)
+	writeTestFile(t, "a.txt", "a\n")
+	writeTestFile(t, "b.txt", "b\n")
+	runTestGit(t, "add", ".")
+	runTestGit(t, "commit", "-q", "-m", "base")
+
+	// a.txt is staged, b.txt is changed in the working tree only, c.txt is untracked
+	writeTestFile(t, "a.txt", "a\na2\n")
+	runTestGit(t, "add", "a.txt")
+	writeTestFile(t, "b.txt", "b\nb2\n")
+	writeTestFile(t, "c.txt", "c\n")
+
+	tests := []struct {
+		name      string
+		all       bool
+		include   bool
+		pathspecs []string
+		modified  []string
+	}{
+		{name: "staged", modified: []string{"a.txt"}},
+		{name: "all", all: true, modified: []string{"a.txt", "b.txt"}},
+		{name: "only", pathspecs: []string{"b.txt"}, modified: []string{"b.txt"}},
+		{name: "include", include: true, pathspecs: []string{"b.txt"}, modified: []string{"a.txt", "b.txt"}},
+	}
+
+	for _, test := range tests {
+		changes, err := GetCommitChanges(test.all, test.include, test.pathspecs...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		sort.Strings(changes.Modified)
+		if !reflect.DeepEqual(changes.Modified, test.modified) {
+			t.Errorf("%s: modified %q, want %q", test.name, changes.Modified, test.modified)
+		}
+		if len(changes.Added) > 0 || len(changes.Unknown) > 0 {
+			t.Errorf("%s: untracked c.txt is not committed, got added %q, unknown %q", test.name, changes.Added, changes.Unknown)
+		}
+	}
+}
+
+// copyTestTree copies the files below src into the working directory
+func copyTestTree(t *testing.T, src string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		writeTestFile(t, rel, string(data))
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+// checkGolden compares the output with the golden file, or rewrites it with -update
+func checkGolden(t *testing.T, golden, got string) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs, got:\n%s", golden, got)
+	}
+}
+
+// TestFormatChangesForPrompt builds a repository from each fixture in testdata/changes
+// and compares the prompt and the detail order with the golden files. The files in
+// before/ are committed, after/ replaces them in the index and worktree/ is written
+// on top without staging.
+func TestFormatChangesForPrompt(t *testing.T) {
+	fixtures, err := filepath.Abs(filepath.Join("testdata", "changes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := os.ReadDir(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range cases {
+		t.Run(fixture.Name(), func(t *testing.T) {
+			dir := filepath.Join(fixtures, fixture.Name())
+			repo := newTestRepo(t)
+			copyTestTree(t, filepath.Join(dir, "before"))
+			runTestGit(t, "add", "-A")
+			runTestGit(t, "commit", "-q", "-m", "before")
+
+			entries, err := os.ReadDir(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != ".git" {
+					if err := os.RemoveAll(entry.Name()); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			copyTestTree(t, filepath.Join(dir, "after"))
+			runTestGit(t, "add", "-A")
+			copyTestTree(t, filepath.Join(dir, "worktree"))
+
+			changes, err := GetChanges()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join(dir, "prompt.golden"), FormatChangesForPrompt(changes))
+			checkGolden(t, filepath.Join(dir, "order.golden"), strings.Join(DetailOrder(changes), "\n")+"\n")
+		})
+	}
+}
+
+func TestExcludeChangesRename(t *testing.T) {
+	newTestRepo(t)
+	writeTestFile(t, "secrets/key.txt", "one\ntwo\nthree\nfour\n")
+	writeTestFile(t, "a.txt", "a\n")
+	runTestGit(t, "add", ".")
+	runTestGit(t, "commit", "-q", "-m", "base")
+
+	// The excluded file is moved out of secrets/ and changed, a.txt is changed
+	runTestGit(t, "mv", "secrets/key.txt", "notes.txt")
+	writeTestFile(t, "notes.txt", "one\ntwo\nthree\nfour\nfive\n")
+	writeTestFile(t, "a.txt", "a\na2\n")
+	runTestGit(t, "add", ".")
+
+	changes, err := GetStagedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from := changes.RenamedFrom["notes.txt"]; from != "secrets/key.txt" {
+		t.Fatalf("RenamedFrom[notes.txt] = %q, want secrets/key.txt", from)
+	}
+
+	ExcludeChanges(changes, ParseIgnoreRules([]string{"secrets/"}))
+	if !reflect.DeepEqual(changes.Modified, []string{"a.txt"}) || changes.Excluded != 1 {
+		t.Errorf("Modified %q, Excluded %d, want [a.txt], 1", changes.Modified, changes.Excluded)
+	}
+	if _, ok := changes.Details["notes.txt"]; ok {
+		t.Error("the diff of notes.txt, renamed from an excluded path, is still in the details")
+	}
+}
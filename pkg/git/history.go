@@ -0,0 +1,131 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// RevListEntry is a commit with its parents, as listed by git rev-list --parents
+type RevListEntry struct {
+	Hash    string
+	Parents []string
+}
+
+// Author is the author of a commit
+type Author struct {
+	Name  string
+	Email string
+	Date  string // In git's raw format, e.g. "1700000000 +0100"
+}
+
+// GetRevList returns the commits in the range with their parents, oldest first,
+// so that parents are listed before their children
+func GetRevList(revRange string) ([]RevListEntry, error) {
+	cmd := exec.Command("git", "rev-list", "--reverse", "--topo-order", "--parents", revRange)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+
+	var entries []RevListEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			entries = append(entries, RevListEntry{Hash: fields[0], Parents: fields[1:]})
+		}
+	}
+	return entries, nil
+}
+
+// GetAuthor returns the author of a commit
+func GetAuthor(rev string) (Author, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%an%x00%ae%x00%ad", "--date=raw", rev)
+	output, err := cmd.Output()
+	if err != nil {
+		return Author{}, gitError(err)
+	}
+	fields := strings.SplitN(strings.TrimRight(string(output), "\n"), "\x00", 3)
+	if len(fields) != 3 {
+		return Author{}, fmt.Errorf("unexpected author of %s: %q", rev, output)
+	}
+	return Author{Name: fields[0], Email: fields[1], Date: fields[2]}, nil
+}
+
+// CommitTree creates a commit object with the tree of rev, the given parents,
+// author and message, without updating any ref, and returns its ID
+func CommitTree(rev string, parents []string, author Author, message string) (string, error) {
+	args := []string{"commit-tree", rev + "^{tree}"}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+author.Name,
+		"GIT_AUTHOR_EMAIL="+author.Email,
+		"GIT_AUTHOR_DATE="+author.Date,
+	)
+	cmd.Stdin = strings.NewReader(message)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// UpdateRef points ref (e.g. "HEAD") to newValue if it still points to oldValue
+func UpdateRef(ref, newValue, oldValue, reason string) error {
+	return runGitCommand(exec.Command("git", "update-ref", "-m", reason, ref, newValue, oldValue))
+}
+
+// FindProtectedPushes returns the remote-tracking branches matching one of the
+// branch globs (e.g. "main" or "release/*") that contain commits of base..HEAD
+func FindProtectedPushes(base string, patterns []string) ([]string, error) {
+	refs, err := listRefs("refs/remotes/")
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := listRemotes()
+	if err != nil {
+		return nil, err
+	}
+
+	var pushed []string
+	for _, ref := range refs {
+		if !isProtected(ref, remotes, patterns) {
+			continue
+		}
+		// The range has commits on ref if their newest common commit comes after base
+		mergeBase, err := GetMergeBase("HEAD", ref)
+		if err != nil {
+			continue
+		}
+		output, err := exec.Command("git", "rev-list", "--count", base+".."+mergeBase).Output()
+		if err != nil {
+			return nil, gitError(err)
+		}
+		if strings.TrimSpace(string(output)) != "0" {
+			pushed = append(pushed, ref)
+		}
+	}
+	return pushed, nil
+}
+
+// isProtected reports whether a remote-tracking ref like "origin/main" is a
+// branch matching one of the patterns
+func isProtected(ref string, remotes []string, patterns []string) bool {
+	for _, remote := range remotes {
+		branch, ok := strings.CutPrefix(ref, remote+"/")
+		if !ok || branch == "HEAD" {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, branch); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package git
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IgnoreFile is the name of the file listing paths whose diff is omitted from prompts
+const IgnoreFile = ".aigitignore"
+
+// defaultIgnorePatterns are lock files, vendored code and minified assets whose diff
+// is rarely useful in a prompt. They can be re-included with "!" in .aigitignore.
+var defaultIgnorePatterns = []string{
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"poetry.lock",
+	"composer.lock",
+	"Gemfile.lock",
+	"vendor/",
+	"node_modules/",
+	"*.min.js",
+	"*.min.css",
+	"*.map",
+}
+
+// IgnoreRule is a single pattern in gitignore syntax
+type IgnoreRule struct {
+	Pattern string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// IgnoreRules is an ordered list of gitignore style rules, the last matching rule wins
+type IgnoreRules []IgnoreRule
+
+// ParseIgnoreRules parses lines in gitignore syntax
+func ParseIgnoreRules(lines []string) IgnoreRules {
+	var rules IgnoreRules
+	for _, line := range lines {
+		if rule, ok := parseIgnoreRule(line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// LoadIgnoreRules loads the default rules followed by the rules in the .aigitignore
+// file at the root of the repository, if there is one
+func LoadIgnoreRules() (IgnoreRules, error) {
+	rules := ParseIgnoreRules(defaultIgnorePatterns)
+
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(root, IgnoreFile))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return append(rules, ParseIgnoreRules(lines)...), nil
+}
+
+// Match reports whether the path, relative to the repository root, is ignored. As in
+// git, a file cannot be re-included by a negated pattern when one of its parent
+// directories is excluded.
+func (rules IgnoreRules) Match(file string) bool {
+	file = strings.TrimPrefix(filepath.ToSlash(file), "/")
+
+	for i, c := range file {
+		if c == '/' && rules.matchPath(file[:i], true) {
+			return true
+		}
+	}
+	return rules.matchPath(file, false)
+}
+
+// matchPath reports whether the last rule matching the path excludes it. Directory
+// patterns only match directories.
+func (rules IgnoreRules) matchPath(path string, dir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if (dir || !rule.dirOnly) && rule.re.MatchString(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// MatchFile reports whether the rules match the path of the file diff or, for a
+// rename or copy, the path it comes from
+func (rules IgnoreRules) MatchFile(file FileDiff) bool {
+	return rules.Match(file.Path) || (file.OldPath != "" && rules.Match(file.OldPath))
+}
+
+// parseIgnoreRule parses a single line in gitignore syntax
+func parseIgnoreRule(line string) (IgnoreRule, bool) {
+	// Trailing spaces are ignored unless they are escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return IgnoreRule{}, false
+	}
+
+	rule := IgnoreRule{Pattern: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return IgnoreRule{}, false
+	}
+
+	// A slash at the beginning or in the middle anchors the pattern to the root
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var re strings.Builder
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			// A class never matches the slash between directories
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				class = "^/" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, "\\", "\\\\") + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(line[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return IgnoreRule{}, false
+	}
+	rule.re = compiled
+	return rule, true
+}
+
+// GetRepoRoot returns the absolute path of the top level directory of the repository
+func GetRepoRoot() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetGeneratedFiles returns the files marked as linguist-generated or
+// linguist-vendored in .gitattributes
+func GetGeneratedFiles(files []string) (map[string]bool, error) {
+	generated := make(map[string]bool)
+	if len(files) == 0 {
+		return generated, nil
+	}
+
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"check-attr", "-z", "linguist-generated", "linguist-vendored", "--"}, files...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// Output is a sequence of NUL terminated path, attribute and value triples
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		value := fields[i+2]
+		if value == "set" || value == "true" {
+			generated[fields[i]] = true
+		}
+	}
+	return generated, nil
+}
+
+// OmitIgnoredDiffs removes the diff content of files matched by the ignore rules or
+// marked as generated in .gitattributes. Those files are listed in changes.Omitted.
+func OmitIgnoredDiffs(changes *Changes, rules IgnoreRules) error {
+	files := make([]string, 0, len(changes.Details))
+	for file := range changes.Details {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	generated, err := GetGeneratedFiles(files)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if generated[file] || rules.Match(file) {
+			delete(changes.Details, file)
+			changes.Omitted = append(changes.Omitted, file)
+		}
+	}
+	return nil
+}
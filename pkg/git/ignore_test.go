@@ -0,0 +1,116 @@
+package git
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestIgnoreRulesMatch compares the rules with git check-ignore on the same patterns
+func TestIgnoreRulesMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		ignored  []string
+		kept     []string
+	}{
+		{
+			name:     "unanchored",
+			patterns: []string{"*.pem", "build"},
+			ignored:  []string{"key.pem", "certs/key.pem", "build/out.txt", "src/build/out.txt"},
+			kept:     []string{"key.pem.txt", "builder/out.txt"},
+		},
+		{
+			name:     "anchored",
+			patterns: []string{"/root.txt", "docs/*.md"},
+			ignored:  []string{"root.txt", "docs/a.md"},
+			kept:     []string{"sub/root.txt", "docs/sub/a.md", "other/docs/a.md"},
+		},
+		{
+			name:     "double star",
+			patterns: []string{"**/logs", "a/**/b.txt", "gen/**"},
+			ignored:  []string{"logs/x", "deep/er/logs/x", "a/b.txt", "a/x/y/b.txt", "gen/x/y.go"},
+			kept:     []string{"x/a/b.txt", "gen.go"},
+		},
+		{
+			name:     "character classes",
+			patterns: []string{"file[0-9].txt", "v[!a-z]", "?.tmp"},
+			ignored:  []string{"file1.txt", "v1", "a.tmp", "dir/b.tmp"},
+			kept:     []string{"filea.txt", "va", "ab.tmp"},
+		},
+		{
+			name:     "directory only",
+			patterns: []string{"cache/"},
+			ignored:  []string{"cache/x", "sub/cache/x"},
+			kept:     []string{"cache", "cache.txt"},
+		},
+		{
+			name:     "negation",
+			patterns: []string{"*.log", "!keep.log"},
+			ignored:  []string{"a.log", "dir/a.log"},
+			kept:     []string{"keep.log", "dir/keep.log"},
+		},
+		{
+			// A file cannot be re-included when its directory is excluded
+			name:     "negation inside excluded directory",
+			patterns: []string{"secrets/", "!secrets/public.txt"},
+			ignored:  []string{"secrets/public.txt", "secrets/key.txt"},
+			kept:     []string{"public.txt"},
+		},
+		{
+			name:     "negation below excluding wildcard",
+			patterns: []string{"*", "!*.go"},
+			ignored:  []string{"pkg/a.go", "a.txt"},
+			kept:     []string{"a.go"},
+		},
+		{
+			name:     "negated directory contents",
+			patterns: []string{"data/*", "!data/keep/"},
+			ignored:  []string{"data/a.txt"},
+			kept:     []string{"data/keep/a.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestRepo(t)
+			writeTestFile(t, ".gitignore", strings.Join(test.patterns, "\n")+"\n")
+			rules := ParseIgnoreRules(test.patterns)
+
+			check := func(files []string, want bool) {
+				for _, file := range files {
+					err := exec.Command("git", "check-ignore", "--no-index", "-q", file).Run()
+					if git := err == nil; git != want {
+						t.Errorf("git check-ignore %s = %v, want %v", file, git, want)
+					}
+					if got := rules.Match(file); got != want {
+						t.Errorf("Match(%s) = %v, want %v", file, got, want)
+					}
+				}
+			}
+			check(test.ignored, true)
+			check(test.kept, false)
+		})
+	}
+}
+
+func TestIgnoreRulesMatchFile(t *testing.T) {
+	rules := ParseIgnoreRules([]string{"secrets/"})
+
+	tests := []struct {
+		file FileDiff
+		want bool
+	}{
+		{FileDiff{Path: "main.go", OldPath: "main.go", Status: Modified}, false},
+		{FileDiff{Path: "secrets/key.pem", OldPath: "secrets/key.pem", Status: Modified}, true},
+		{FileDiff{Path: "notes.txt", OldPath: "secrets/key.pem", Status: Modified}, true},
+		{FileDiff{Path: "secrets/key.pem", OldPath: "notes.txt", Status: Modified}, true},
+		{FileDiff{Path: "notes.txt", Status: Added}, false},
+	}
+
+	for _, test := range tests {
+		if got := rules.MatchFile(test.file); got != test.want {
+			t.Errorf("MatchFile(%s -> %s) = %v, want %v", test.file.OldPath, test.file.Path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GetWorkingTreeDiff returns the structured diff between HEAD and the working tree,
+// including untracked files that are not ignored. Renames are reported as a
+// deletion and an addition so that each file can be staged on its own.
+func GetWorkingTreeDiff(pathspecs ...string) ([]FileDiff, error) {
+	args := withPathspecs([]string{"diff", "HEAD", "--no-color", "--no-ext-diff", "--no-renames", "--src-prefix=a/", "--dst-prefix=b/"}, pathspecs)
+	cmd := exec.Command("git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	files := ParseDiff(string(output))
+
+	cmd = exec.Command("git", withPathspecs([]string{"ls-files", "--others", "--exclude-standard", "-z"}, pathspecs)...)
+	output, err = cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path == "" {
+			continue
+		}
+		// git diff --no-index exits with status 1 when the files differ
+		cmd = exec.Command("git", "diff", "--no-index", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "--", "/dev/null", path)
+		diff, err := cmd.Output()
+		if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == 1) {
+			return nil, gitError(err)
+		}
+		for _, file := range ParseDiff(string(diff)) {
+			file.Path, file.OldPath, file.Status = path, path, Added
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Patch returns a patch for the hunks of the file with the given indexes, which can
+// be applied with git apply
+func (file FileDiff) Patch(hunks []int) string {
+	var sb strings.Builder
+	for _, line := range file.Header {
+		sb.WriteString(line + "\n")
+	}
+	for _, i := range hunks {
+		hunk := file.Hunks[i]
+		sb.WriteString(hunk.Header + "\n")
+		for _, line := range hunk.Lines {
+			sb.WriteString(string(line.Kind) + line.Text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// ApplyToIndex applies a patch to the index without touching the working tree
+func ApplyToIndex(patch string) error {
+	cmd := exec.Command("git", "apply", "--cached", "-")
+	cmd.Stdin = strings.NewReader(patch)
+	return runGitCommand(cmd)
+}
+
+// StageFiles stages the whole content of the files, including deletions
+func StageFiles(paths ...string) error {
+	return runGitCommand(exec.Command("git", append([]string{"add", "-A", "--"}, paths...)...))
+}
+
+// WriteTree writes the index to a tree object and returns its ID
+func WriteTree() (string, error) {
+	cmd := exec.Command("git", "write-tree")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ReadTree replaces the index with the content of a tree or commit
+func ReadTree(tree string) error {
+	return runGitCommand(exec.Command("git", "read-tree", tree))
+}
+
+// ResetSoft moves HEAD to a commit, keeping the index and the working tree
+func ResetSoft(rev string) error {
+	return runGitCommand(exec.Command("git", "reset", "--soft", rev))
+}
+
+// GetHead returns the commit ID of HEAD
+func GetHead() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// runGitCommand runs a git command whose output is only of interest if it fails
+func runGitCommand(cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Commit represents a commit in the history
+type Commit struct {
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// ShortHash returns the abbreviated commit hash
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+	return c.Hash
+}
+
+// Message returns the full commit message
+func (c Commit) Message() string {
+	if c.Body == "" {
+		return c.Subject
+	}
+	return c.Subject + "\n\n" + c.Body
+}
+
+// GetCommits returns the commits selected by the git log arguments, e.g. "main..HEAD",
+// oldest first
+func GetCommits(args ...string) ([]Commit, error) {
+	// Fields are separated by the unit separator and commits by the record separator
+	logArgs := append([]string{"log", "--reverse", "--format=%H%x1f%an%x1f%ad%x1f%s%x1f%b%x1e", "--date=short"}, args...)
+	cmd := exec.Command("git", logArgs...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(string(output), "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) < 5 {
+			continue
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    fields[2],
+			Subject: fields[3],
+			Body:    strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
+
+// GetMergeBase returns the best common ancestor of two revisions
+func GetMergeBase(a, b string) (string, error) {
+	cmd := exec.Command("git", "merge-base", a, b)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsAncestor reports whether the commit a is an ancestor of b, or the same commit
+func IsAncestor(a, b string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", a, b).Run() == nil
+}
+
+// GetDefaultBranch returns the branch pull requests are usually merged into: the
+// default branch of origin if it is known, otherwise main or master
+func GetDefaultBranch() (string, error) {
+	cmd := exec.Command("git", "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if output, err := cmd.Output(); err == nil {
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	for _, branch := range []string{"main", "master", "origin/main", "origin/master"} {
+		if RevisionExists(branch) {
+			return branch, nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine the default branch")
+}
+
+// GetCommitDate returns the commit date of a revision as YYYY-MM-DD
+func GetCommitDate(rev string) (string, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%cd", "--date=short", rev)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetCurrentBranch returns the name of the checked out branch, or "HEAD" if detached
+func GetCurrentBranch() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// prTemplatePaths are the locations of pull request templates used by GitHub and GitLab
+var prTemplatePaths = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+	".gitlab/merge_request_templates/Default.md",
+}
+
+// FindPRTemplate returns the content of the repository's pull request template,
+// or "" if there is none
+func FindPRTemplate() (string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range prTemplatePaths {
+		content, err := os.ReadFile(filepath.Join(root, path))
+		if err == nil {
+			return string(content), nil
+		}
+	}
+	return "", nil
+}
+
+// gitError adds git's error output to the error of a failed command
+func gitError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
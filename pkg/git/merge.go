@@ -0,0 +1,84 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// MergeState describes a merge that is waiting to be committed
+type MergeState struct {
+	Heads          []string // The commits being merged in, from MERGE_HEAD
+	DefaultMessage string   // Git's message from MERGE_MSG without comments, e.g. "Merge branch 'feature'"
+	Conflicts      []string // The paths that had conflicts, as listed in MERGE_MSG
+}
+
+// GetMergeState returns the merge in progress, or nil if there is none
+func GetMergeState() (*MergeState, error) {
+	headPath := strings.TrimSpace(gitOutput("rev-parse", "--git-path", "MERGE_HEAD"))
+	if headPath == "" {
+		return nil, nil
+	}
+	heads, err := os.ReadFile(headPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &MergeState{Heads: strings.Fields(string(heads))}
+
+	// MERGE_MSG lists the conflicts in comments: "# Conflicts:" followed by "#\tpath"
+	msgPath := strings.TrimSpace(gitOutput("rev-parse", "--git-path", "MERGE_MSG"))
+	msg, err := os.ReadFile(msgPath)
+	if err != nil {
+		return state, nil
+	}
+	var lines []string
+	inConflicts := false
+	for _, line := range strings.Split(string(msg), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+			inConflicts = false
+			continue
+		}
+		comment := strings.TrimPrefix(line, "#")
+		switch {
+		case strings.TrimSpace(comment) == "Conflicts:":
+			inConflicts = true
+		case inConflicts && strings.HasPrefix(comment, "\t"):
+			state.Conflicts = append(state.Conflicts, strings.TrimSpace(comment))
+		case inConflicts && strings.TrimSpace(comment) != "":
+			inConflicts = false
+		}
+	}
+	state.DefaultMessage = strings.TrimSpace(strings.Join(lines, "\n"))
+	return state, nil
+}
+
+// GetResolutionDiff returns how the staged content of the paths differs from git's
+// automatic merge of HEAD and head with conflict markers, i.e. how the conflicts were
+// resolved. It returns "" if the automatic merge cannot be recreated (git before 2.38).
+func GetResolutionDiff(head string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	// merge-tree exits with 1 when there are conflicts, the tree is on the first line
+	output, err := exec.Command("git", "merge-tree", "--write-tree", "--no-messages", "HEAD", head).Output()
+	if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == 1) {
+		return "", nil
+	}
+	tree, _, _ := strings.Cut(string(output), "\n")
+	if tree == "" {
+		return "", nil
+	}
+
+	args := append([]string{"diff", "--cached", tree, "--"}, paths...)
+	diff, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return string(diff), nil
+}
@@ -0,0 +1,237 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	// refLabelPattern matches labels and commands models put in front of a branch name
+	refLabelPattern = regexp.MustCompile(`(?i)^(?:(?:the\s+|your\s+)?(?:suggested\s+|new\s+)?branch(?:\s+name)?(?:\s*[:=]\s*|\s+is\s*:?\s+)|(?:git\s+)?(?:checkout\s+-b|switch\s+-c|branch)\s+)`)
+	// refInlineCodePattern matches a name quoted as inline code
+	refInlineCodePattern = regexp.MustCompile("`([^`]+)`")
+	// refWhitespacePattern matches runs of whitespace
+	refWhitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeBranchName turns model output into a valid branch name following the rules
+// of git check-ref-format --branch. Explanations, code fences, quotes and invalid
+// characters are removed. It returns "" if nothing usable remains.
+func SanitizeBranchName(raw string) string {
+	// Use the first line that is not empty, a code fence or an introduction like "Here it is:"
+	name := ""
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "```") && !strings.HasSuffix(line, ":") {
+			name = line
+			break
+		}
+	}
+
+	// Prefer a name quoted as inline code within an explanation
+	if match := refInlineCodePattern.FindStringSubmatch(name); match != nil {
+		name = match[1]
+	}
+
+	name = strings.Trim(name, "`'\"*_ ")
+	name = refLabelPattern.ReplaceAllString(name, "")
+	name = strings.Trim(name, "`'\"*_ ")
+	name = refWhitespacePattern.ReplaceAllString(name, "-")
+
+	// Remove control characters and characters that are never allowed in refs
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\`'\"", r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	for strings.Contains(name, "..") {
+		name = strings.ReplaceAll(name, "..", ".")
+	}
+	name = strings.ReplaceAll(name, "@{", "@")
+
+	// Components must not be empty, start with "." or end with ".lock". Trimming the
+	// end of the name can expose a new ".lock" suffix, so repeat until nothing changes.
+	for {
+		previous := name
+		var components []string
+		for _, component := range strings.Split(name, "/") {
+			component = strings.TrimLeft(component, ".")
+			for strings.HasSuffix(component, ".lock") {
+				component = strings.TrimSuffix(component, ".lock")
+			}
+			if component != "" {
+				components = append(components, component)
+			}
+		}
+		name = strings.Join(components, "/")
+		name = strings.TrimRight(name, "./")
+		name = strings.TrimLeft(name, "-")
+		if name == previous {
+			break
+		}
+	}
+
+	if ValidateBranchName(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// ValidateBranchName returns an error describing the first git check-ref-format rule
+// the branch name violates, or nil if it is valid
+func ValidateBranchName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("branch name is empty")
+	case name == "@":
+		return fmt.Errorf("branch name cannot be %q", name)
+	case name == "HEAD":
+		return fmt.Errorf("branch name cannot be %q", name)
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("branch name %q cannot start with '-'", name)
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return fmt.Errorf("branch name %q cannot start or end with '/'", name)
+	case strings.HasSuffix(name, "."):
+		return fmt.Errorf("branch name %q cannot end with '.'", name)
+	case strings.Contains(name, ".."):
+		return fmt.Errorf("branch name %q cannot contain '..'", name)
+	case strings.Contains(name, "//"):
+		return fmt.Errorf("branch name %q cannot contain consecutive slashes", name)
+	case strings.Contains(name, "@{"):
+		return fmt.Errorf("branch name %q cannot contain '@{'", name)
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("branch name %q cannot contain %q", name, r)
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return fmt.Errorf("branch name %q has a component starting with '.'", name)
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("branch name %q has a component ending with '.lock'", name)
+		}
+	}
+	return nil
+}
+
+// BranchCollision describes an existing ref that conflicts with a new branch name
+type BranchCollision struct {
+	Ref    string // The conflicting ref, e.g. "feature/login" or "origin/feature/login"
+	Remote bool   // The ref is a remote-tracking branch
+}
+
+// branchRefs are the existing branches used to detect collisions
+type branchRefs struct {
+	local   []string
+	remote  []string
+	remotes []string
+}
+
+// loadBranchRefs lists the local and remote-tracking branches and the remotes
+func loadBranchRefs() (*branchRefs, error) {
+	local, err := listRefs("refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	remote, err := listRefs("refs/remotes/")
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := listRemotes()
+	if err != nil {
+		return nil, err
+	}
+	return &branchRefs{local: local, remote: remote, remotes: remotes}, nil
+}
+
+// FindBranchCollisions returns the local and remote branches that conflict with the
+// name. Besides exact matches, a local branch "a" conflicts with "a/b" and vice versa
+// because both cannot exist as files in .git/refs.
+func FindBranchCollisions(name string) ([]BranchCollision, error) {
+	refs, err := loadBranchRefs()
+	if err != nil {
+		return nil, err
+	}
+	return refs.collisions(name), nil
+}
+
+// collisions returns the branches that conflict with the name
+func (refs *branchRefs) collisions(name string) []BranchCollision {
+	var collisions []BranchCollision
+	for _, branch := range refs.local {
+		if branch == name || strings.HasPrefix(name, branch+"/") || strings.HasPrefix(branch, name+"/") {
+			collisions = append(collisions, BranchCollision{Ref: branch})
+		}
+	}
+	for _, ref := range refs.remote {
+		for _, remote := range refs.remotes {
+			if ref == remote+"/"+name {
+				collisions = append(collisions, BranchCollision{Ref: ref, Remote: true})
+			}
+		}
+	}
+	return collisions
+}
+
+// SuggestBranchName returns the first of name-2, name-3, ... without collisions.
+// If a branch named like one of the parent directories of name exists, suffixes
+// cannot help, so the flattened name with "/" replaced by "-" is tried as well.
+func SuggestBranchName(name string) (string, error) {
+	refs, err := loadBranchRefs()
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for i := 2; i < 100; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s-%d", name, i))
+	}
+	if flat := strings.ReplaceAll(name, "/", "-"); flat != name {
+		candidates = append(candidates, flat)
+		for i := 2; i < 100; i++ {
+			candidates = append(candidates, fmt.Sprintf("%s-%d", flat, i))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if len(refs.collisions(candidate)) == 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free branch name found for %q", name)
+}
+
+// listRefs returns the short names of the refs below the prefix
+func listRefs(prefix string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)", prefix)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			refs = append(refs, strings.TrimPrefix(line, prefix))
+		}
+	}
+	return refs, nil
+}
+
+// listRemotes returns the names of the configured remotes
+func listRemotes() ([]string, error) {
+	cmd := exec.Command("git", "remote")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
@@ -0,0 +1,80 @@
+package git
+
+import "testing"
+
+func TestSanitizeBranchName(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"feature/login-timeout", "feature/login-timeout"},
+		{"```\nfeature/add-login\n```", "feature/add-login"},
+		{"Here is a branch name:\n\nfix/typo", "fix/typo"},
+		{"Branch name: `fix/typo`", "fix/typo"},
+		{"The suggested branch name is: feat/x", "feat/x"},
+		{"git checkout -b feat/x", "feat/x"},
+		{"\"docs/readme\"", "docs/readme"},
+		{"add login timeout", "add-login-timeout"},
+		{"feat: add ~^:?*[\\ thing", "feat-add--thing"},
+		{"control\x01char\x7f", "controlchar"},
+		{"feature..x", "feature.x"},
+		{"x@{1}", "x@1}"},
+		{"feature//x", "feature/x"},
+		{"..hidden/.x", "hidden/x"},
+		{"foo.lock/bar", "foo/bar"},
+		{"foo/bar.lock.", "foo/bar"},
+		{"a/b.lock.lock.", "a/b"},
+		{"a/b.lock/.", "a/b"},
+		{"a/b.lock./.lock.", "a/b.lock./lock"},
+		{"--.foo.lock./", "foo"},
+		{"-feature", "feature"},
+		{"/feature/x/", "feature/x"},
+		{"@", ""},
+		{"HEAD", ""},
+		{"-.lock", ""},
+		{".lock.", "lock"},
+		{"...", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		got := SanitizeBranchName(test.raw)
+		if got != test.want {
+			t.Errorf("SanitizeBranchName(%q) = %q, want %q", test.raw, got, test.want)
+		}
+		if got != "" {
+			if err := ValidateBranchName(got); err != nil {
+				t.Errorf("SanitizeBranchName(%q) = %q is invalid: %v", test.raw, got, err)
+			}
+		}
+	}
+}
+
+func TestValidateBranchName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"feature/x", true},
+		{"fix/JIRA-123-login", true},
+		{"", false},
+		{"@", false},
+		{"HEAD", false},
+		{"-x", false},
+		{"x/", false},
+		{"x.", false},
+		{"a..b", false},
+		{"a//b", false},
+		{"a@{b", false},
+		{"a b", false},
+		{"a/.b", false},
+		{"a/b.lock", false},
+	}
+
+	for _, test := range tests {
+		err := ValidateBranchName(test.name)
+		if (err == nil) != test.valid {
+			t.Errorf("ValidateBranchName(%q) = %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
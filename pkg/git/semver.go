@@ -0,0 +1,83 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a release version following Semantic Versioning, e.g. "v1.2.3"
+type Version struct {
+	Prefix string // "v" or ""
+	Major  int
+	Minor  int
+	Patch  int
+}
+
+// versionPattern matches release tags, pre-releases like "v1.2.3-rc.1" are not releases
+var versionPattern = regexp.MustCompile(`^(v?)(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// ParseVersion parses a release tag like "v1.2.3" or "1.2.3"
+func ParseVersion(tag string) (Version, bool) {
+	match := versionPattern.FindStringSubmatch(tag)
+	if match == nil {
+		return Version{}, false
+	}
+	major, _ := strconv.Atoi(match[2])
+	minor, _ := strconv.Atoi(match[3])
+	patch, _ := strconv.Atoi(match[4])
+	return Version{Prefix: match[1], Major: major, Minor: minor, Patch: patch}, true
+}
+
+// String returns the version as a tag name
+func (v Version) String() string {
+	return fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is an older version than other
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// Bump returns the next version for a "major", "minor" or "patch" release
+func (v Version) Bump(level string) Version {
+	switch level {
+	case "major":
+		return Version{Prefix: v.Prefix, Major: v.Major + 1}
+	case "minor":
+		return Version{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return Version{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
+
+// FindLastVersion returns the highest release tag reachable from rev. It reports
+// false if there is none.
+func FindLastVersion(rev string) (Version, bool, error) {
+	output, err := exec.Command("git", "tag", "--list", "--merged", rev).Output()
+	if err != nil {
+		return Version{}, false, gitError(err)
+	}
+
+	var last Version
+	found := false
+	for _, tag := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if version, ok := ParseVersion(tag); ok && (!found || last.Less(version)) {
+			last, found = version, true
+		}
+	}
+	return last, found, nil
+}
+
+// TagExists reports whether a tag with the name exists
+func TagExists(name string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "--quiet", "refs/tags/"+name).Run() == nil
+}
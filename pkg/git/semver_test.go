@@ -0,0 +1,92 @@
+package git
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Version
+		ok   bool
+	}{
+		{"v1.2.3", Version{Prefix: "v", Major: 1, Minor: 2, Patch: 3}, true},
+		{"1.2.3", Version{Major: 1, Minor: 2, Patch: 3}, true},
+		{"v0.10.0", Version{Prefix: "v", Minor: 10}, true},
+		{"v1.2.3-rc.1", Version{}, false},
+		{"v1.2.3+build", Version{}, false},
+		{"v01.2.3", Version{}, false},
+		{"v1.2", Version{}, false},
+		{"release-1.2.3", Version{}, false},
+		{"V1.2.3", Version{}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseVersion(test.tag)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ParseVersion(%q) = %+v, %v, want %+v, %v", test.tag, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestVersionBump(t *testing.T) {
+	tests := []struct {
+		version string
+		level   string
+		want    string
+	}{
+		{"v1.2.3", "major", "v2.0.0"},
+		{"v1.2.3", "minor", "v1.3.0"},
+		{"v1.2.3", "patch", "v1.2.4"},
+		{"0.9.9", "major", "1.0.0"},
+		{"0.9.9", "minor", "0.10.0"},
+		{"0.9.9", "patch", "0.9.10"},
+	}
+
+	for _, test := range tests {
+		version, _ := ParseVersion(test.version)
+		if got := version.Bump(test.level).String(); got != test.want {
+			t.Errorf("%s bumped for a %s release = %s, want %s", test.version, test.level, got, test.want)
+		}
+	}
+}
+
+func TestFindLastVersion(t *testing.T) {
+	newTestRepo(t)
+	commit := func(message string, tags ...string) {
+		t.Helper()
+		runTestGit(t, "commit", "-q", "--allow-empty", "-m", message)
+		for _, tag := range tags {
+			runTestGit(t, "tag", tag)
+		}
+	}
+
+	commit("first")
+	if _, found, err := FindLastVersion("HEAD"); err != nil || found {
+		t.Fatalf("FindLastVersion without tags = %v, %v, want not found", found, err)
+	}
+
+	// Versions are compared numerically, pre-releases and other tags are skipped
+	commit("second", "v1.9.0", "latest")
+	commit("third", "v1.10.0")
+	commit("fourth", "v2.0.0-rc.1", "1.10.1")
+
+	// A tag on another branch is not reachable from HEAD
+	runTestGit(t, "checkout", "-q", "-b", "other")
+	commit("other", "v3.0.0")
+	runTestGit(t, "checkout", "-q", "main")
+
+	tests := []struct {
+		rev  string
+		want string
+	}{
+		{"HEAD", "1.10.1"},
+		{"HEAD~1", "v1.10.0"},
+		{"HEAD~2", "v1.9.0"},
+		{"other", "v3.0.0"},
+	}
+	for _, test := range tests {
+		version, found, err := FindLastVersion(test.rev)
+		if err != nil || !found || version.String() != test.want {
+			t.Errorf("FindLastVersion(%q) = %s, %v, %v, want %s", test.rev, version, found, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package git
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// StashEntry is an entry of the stash list
+type StashEntry struct {
+	Ref     string // e.g. "stash@{0}"
+	Hash    string // The stash commit
+	Subject string // e.g. "WIP on main: 1a2b3c4 Add login form"
+}
+
+// Base returns the commit the stash was created on
+func (s StashEntry) Base() string {
+	return s.Hash + "^1"
+}
+
+// GetStashes returns the stash entries, newest first. The arguments are passed
+// to git stash list, e.g. "-n", "5".
+func GetStashes(args ...string) ([]StashEntry, error) {
+	listArgs := append([]string{"stash", "list", "--format=%gd%x1f%H%x1f%gs"}, args...)
+	output, err := exec.Command("git", listArgs...).Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	return parseStashList(string(output)), nil
+}
+
+// parseStashList parses git stash list output in the "%gd%x1f%H%x1f%gs" format.
+// Subjects may contain any other character, so only the first two separators split.
+func parseStashList(output string) []StashEntry {
+	var entries []StashEntry
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		fields := strings.SplitN(line, "\x1f", 3)
+		if len(fields) == 3 {
+			entries = append(entries, StashEntry{Ref: fields[0], Hash: fields[1], Subject: fields[2]})
+		}
+	}
+	return entries
+}
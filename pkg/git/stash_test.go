@@ -0,0 +1,48 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStashList(t *testing.T) {
+	output := "stash@{0}\x1fa1b2c3\x1fOn main: fix: handle a\x1fb in subjects\n" +
+		"stash@{1}\x1fd4e5f6\x1fWIP on feature/x: 1a2b3c4 Add login form\n" +
+		"malformed line\n"
+
+	want := []StashEntry{
+		{Ref: "stash@{0}", Hash: "a1b2c3", Subject: "On main: fix: handle a\x1fb in subjects"},
+		{Ref: "stash@{1}", Hash: "d4e5f6", Subject: "WIP on feature/x: 1a2b3c4 Add login form"},
+	}
+	if got := parseStashList(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStashList = %+v, want %+v", got, want)
+	}
+	if got := parseStashList(""); len(got) != 0 {
+		t.Errorf("parseStashList(\"\") = %+v, want none", got)
+	}
+}
+
+func TestGetStashes(t *testing.T) {
+	newTestRepo(t)
+	writeTestFile(t, "a.txt", "a\n")
+	runTestGit(t, "add", ".")
+	runTestGit(t, "commit", "-q", "-m", "base")
+	writeTestFile(t, "a.txt", "a\nb\n")
+	runTestGit(t, "stash", "push", "-q", "-m", "first: a | b")
+	writeTestFile(t, "a.txt", "a\nc\n")
+	runTestGit(t, "stash", "push", "-q", "-m", "second")
+
+	stashes, err := GetStashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stashes) != 2 {
+		t.Fatalf("GetStashes returned %d entries, want 2", len(stashes))
+	}
+	if stashes[0].Ref != "stash@{0}" || stashes[0].Subject != "On main: second" || len(stashes[0].Hash) != 40 {
+		t.Errorf("newest stash = %+v", stashes[0])
+	}
+	if stashes[1].Ref != "stash@{1}" || stashes[1].Subject != "On main: first: a | b" {
+		t.Errorf("oldest stash = %+v", stashes[1])
+	}
+}
@@ -0,0 +1,5 @@
+package pkg
+
+func Double(a int) int {
+	return Add(a, a)
+}
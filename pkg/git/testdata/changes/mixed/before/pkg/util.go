@@ -0,0 +1,5 @@
+package pkg
+
+func Add(a, b int) int {
+	return a + b
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// newPRDescriptionCmd creates the pr-description command
+func newPRDescriptionCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "pr-description [base]",
+		Short: "Generate a pull request description for the current branch",
+		Long: "Generate a Markdown pull request description from the commits and the combined diff " +
+			"between the merge base with base (the default branch if omitted) and HEAD. " +
+			"The repository's pull request template is used if there is one.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base := ""
+			if len(args) > 0 {
+				base = args[0]
+			}
+			return handlePRDescription(loadConfig(), base, output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the description to a file instead of stdout")
+	return cmd
+}
+
+// handlePRDescription generates a pull request description for base...HEAD
+func handlePRDescription(config ai.Config, base, output string) error {
+	if base == "" {
+		defaultBranch, err := git.GetDefaultBranch()
+		if err != nil {
+			return fmt.Errorf("%w, pass the base branch explicitly", err)
+		}
+		base = defaultBranch
+	}
+
+	mergeBase, err := git.GetMergeBase(base, "HEAD")
+	if err != nil {
+		return fmt.Errorf("finding the merge base with %s: %w", base, err)
+	}
+
+	commits, err := git.GetCommits(mergeBase + "..HEAD")
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits between %s and HEAD", base)
+	}
+
+	changes, err := git.GetDiffChanges(mergeBase, "HEAD")
+	if err != nil {
+		return fmt.Errorf("getting git changes: %w", err)
+	}
+
+	// Format changes for the prompt
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		return &generationError{fmt.Errorf("summarizing git changes: %w", err)}
+	}
+
+	template, err := git.FindPRTemplate()
+	if err != nil {
+		return fmt.Errorf("reading the pull request template: %w", err)
+	}
+
+	branch, err := git.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	// Create prompt
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Write a pull request description for merging the branch %s into %s.\n\n", branch, base)
+	prompt.WriteString("Commits:\n")
+	for _, commit := range commits {
+		prompt.WriteString("- " + commit.Subject + "\n")
+		if commit.Body != "" {
+			prompt.WriteString("  " + strings.ReplaceAll(commit.Body, "\n", "\n  ") + "\n")
+		}
+	}
+	prompt.WriteString("\n" + formattedChanges + "\n")
+	prompt.WriteString("Start with the title as a level 1 Markdown heading. ")
+	if template != "" {
+		prompt.WriteString("Then fill in the repository's pull request template below, keeping its headings and checklists:\n\n" + template)
+	} else {
+		prompt.WriteString("Then add the sections \"## Summary\" (what and why in a few sentences), \"## Changes\" (a bullet list) and \"## Testing\" (how the changes were or should be tested).")
+	}
+	prompt.WriteString("\nJust give me the Markdown, no explanation needed.")
+
+	fmt.Fprintf(os.Stderr, "Generating description for %d commit(s) since %s...\n", len(commits), base)
+	description, err := ai.GeneratePRDescription(prompt.String(), config)
+	if err != nil {
+		return &generationError{fmt.Errorf("generating description: %w", err)}
+	}
+	description = trimCodeFence(description) + "\n"
+
+	if output == "" {
+		fmt.Print(description)
+		return nil
+	}
+	if err := os.WriteFile(output, []byte(description), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Description written to %s\n", output)
+	return nil
+}
+
+// trimCodeFence removes a Markdown code fence wrapped around the whole text
+func trimCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") {
+		return text
+	}
+	text = strings.TrimSuffix(text, "```")
+	if i := strings.Index(text, "\n"); i >= 0 {
+		text = text[i+1:]
+	} else {
+		text = ""
+	}
+	return strings.TrimSpace(text)
+}
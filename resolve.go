@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// conflictContextLines is the number of lines around a conflict sent as context
+const conflictContextLines = 10
+
+// resolveChoice is the user's decision on a proposed resolution
+type resolveChoice int
+
+const (
+	choiceReject resolveChoice = iota
+	choiceAccept
+	choiceQuit
+)
+
+// newResolveCmd creates the resolve command
+func newResolveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resolve [<path>...]",
+		Short: "Resolve merge conflicts with proposed resolutions",
+		Long: "Propose a resolution for each conflict left by a merge, rebase or cherry-pick, " +
+			"showing our and their side next to each other. Accepted resolutions are written to the file, " +
+			"and files are staged once all of their conflicts are resolved.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleResolve(loadConfig(), args)
+		},
+	}
+}
+
+// handleResolve resolves the conflicts in the unmerged paths, limited to the given paths
+func handleResolve(config ai.Config, paths []string) error {
+	// Unmerged paths are relative to the repository root, so work from there
+	root, err := git.GetRepoRoot()
+	if err != nil {
+		return err
+	}
+	for i, path := range paths {
+		if absolute, err := filepath.Abs(path); err == nil {
+			if relative, err := filepath.Rel(root, absolute); err == nil {
+				paths[i] = filepath.ToSlash(relative)
+			}
+		}
+	}
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+
+	unmerged, err := git.GetUnmergedPaths()
+	if err != nil {
+		return fmt.Errorf("listing conflicts: %w", err)
+	}
+	unmerged = filterUnmergedPaths(unmerged, paths)
+	if len(unmerged) == 0 {
+		fmt.Println("No conflicts to resolve")
+		return nil
+	}
+
+	context, err := git.GetRepoContext()
+	if err != nil {
+		return fmt.Errorf("reading the repository state: %w", err)
+	}
+	policyRules := git.ParseIgnoreRules(config.Policy.ExcludePaths)
+
+	staged := 0
+	for _, path := range unmerged {
+		if !path.BothModified() {
+			fmt.Printf("%s: %s, resolve it with git add or git rm\n", path.Path, path.Description())
+			continue
+		}
+		if policyRules.Match(path.Path) {
+			fmt.Printf("%s: excluded by ai-git.excludePaths, resolve it manually\n", path.Path)
+			continue
+		}
+
+		resolved, quit, err := resolveFile(config, path.Path, context.Operation)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", path.Path, err)
+		}
+		if resolved {
+			staged++
+		}
+		if quit {
+			break
+		}
+	}
+
+	fmt.Printf("\nResolved and staged %d of %d conflicted file(s)\n", staged, len(unmerged))
+	return nil
+}
+
+// filterUnmergedPaths keeps the paths that are or are inside one of the given paths
+func filterUnmergedPaths(unmerged []git.UnmergedPath, paths []string) []git.UnmergedPath {
+	if len(paths) == 0 {
+		return unmerged
+	}
+	var filtered []git.UnmergedPath
+	for _, u := range unmerged {
+		for _, path := range paths {
+			path = strings.TrimSuffix(path, "/")
+			if u.Path == path || strings.HasPrefix(u.Path, path+"/") {
+				filtered = append(filtered, u)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// resolveFile proposes a resolution for each conflict in the file and lets the user
+// accept, reject or edit it. Accepted resolutions are written to the file, which is
+// staged if no conflicts remain. It reports whether the file was staged and whether
+// the user asked to quit.
+func resolveFile(config ai.Config, path, operation string) (bool, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, false, err
+	}
+	lines := strings.Split(string(content), "\n")
+
+	hunks := git.ParseConflicts(lines)
+	if len(hunks) == 0 {
+		fmt.Printf("%s: no conflict markers left, stage it with git add once it is resolved\n", path)
+		return false, false, nil
+	}
+	if err := git.GetConflictBases(path, hunks); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not determine the common ancestor of %s: %v\n", path, err)
+	}
+
+	resolutions := make(map[int][]string)
+	quit := false
+	for i, hunk := range hunks {
+		fmt.Printf("\n%s: conflict %d of %d (lines %d-%d)\n\n", path, i+1, len(hunks), hunk.Start+1, hunk.End+1)
+		fmt.Print(sideBySide("ours: "+hunk.OursLabel, hunk.Ours, "theirs: "+hunk.TheirsLabel, hunk.Theirs, terminalWidth()))
+
+		resolution, rationale, err := generateResolution(config, path, lines, hunks, i, operation)
+		if err != nil {
+			return false, false, err
+		}
+
+		choice, err := chooseResolution(&resolution, rationale, hunk)
+		if err != nil {
+			return false, false, err
+		}
+		if choice == choiceAccept {
+			resolutions[i] = resolution
+		}
+		if choice == choiceQuit {
+			quit = true
+			break
+		}
+	}
+
+	if len(resolutions) == 0 {
+		return false, quit, nil
+	}
+
+	// Replace the accepted conflicts, keeping the markers of the others
+	var result []string
+	next := 0
+	for i, hunk := range hunks {
+		resolution, ok := resolutions[i]
+		if !ok {
+			continue
+		}
+		result = append(result, lines[next:hunk.Start]...)
+		result = append(result, resolution...)
+		next = hunk.End + 1
+	}
+	result = append(result, lines[next:]...)
+
+	mode, err := resolvedFileMode(path)
+	if err != nil {
+		return false, quit, err
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(result, "\n")), mode); err != nil {
+		return false, quit, err
+	}
+	if len(resolutions) < len(hunks) {
+		fmt.Printf("%s: %d of %d conflict(s) resolved, the rest are left for you\n", path, len(resolutions), len(hunks))
+		return false, quit, nil
+	}
+	if err := git.StageFiles(path); err != nil {
+		return false, quit, err
+	}
+	fmt.Printf("%s: resolved and staged\n", path)
+	return true, quit, nil
+}
+
+// resolvedFileMode returns the permissions to write the resolved file with: its own,
+// or the ones git records if it was deleted in the meantime
+func resolvedFileMode(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err == nil {
+		return info.Mode().Perm(), nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+	return git.GetIndexMode(path)
+}
+
+// chooseResolution shows the proposed resolution and asks what to do with it.
+// Editing or picking a side replaces the resolution.
+func chooseResolution(resolution *[]string, rationale string, hunk git.ConflictHunk) (resolveChoice, error) {
+	fmt.Println("\nProposed resolution:")
+	for _, line := range *resolution {
+		fmt.Println("  | " + line)
+	}
+	if rationale != "" {
+		fmt.Println("\nWhy: " + rationale)
+	}
+
+	for {
+		switch strings.ToLower(promptLine("\n[a]ccept, [r]eject, [e]dit, take [o]urs, take [t]heirs or [q]uit? ")) {
+		case "a", "accept":
+			return choiceAccept, nil
+		case "", "r", "reject":
+			return choiceReject, nil
+		case "e", "edit":
+			edited, err := editCode(strings.Join(*resolution, "\n"), "ai-git-resolution-*.txt")
+			if err != nil {
+				return choiceReject, err
+			}
+			*resolution = strings.Split(edited, "\n")
+			if edited == "" {
+				*resolution = nil
+			}
+			return choiceAccept, nil
+		case "o", "ours":
+			*resolution = hunk.Ours
+			return choiceAccept, nil
+		case "t", "theirs":
+			*resolution = hunk.Theirs
+			return choiceAccept, nil
+		case "q", "quit":
+			return choiceQuit, nil
+		}
+	}
+}
+
+// generateResolution asks the AI model to resolve the i-th conflict and returns the
+// resolved lines and the rationale. The context stops at neighbouring conflicts.
+func generateResolution(config ai.Config, path string, lines []string, hunks []git.ConflictHunk, i int, operation string) ([]string, string, error) {
+	hunk := hunks[i]
+	first, last := 0, len(lines)
+	if i > 0 {
+		first = hunks[i-1].End + 1
+	}
+	if i+1 < len(hunks) {
+		last = hunks[i+1].Start
+	}
+	before := lines[max(first, hunk.Start-conflictContextLines):hunk.Start]
+	after := lines[hunk.End+1 : min(last, hunk.End+1+conflictContextLines)]
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Resolve this conflict in %s", path)
+	switch operation {
+	case "":
+	case "rebase", "rebase or am":
+		prompt.WriteString(" during a rebase, where ours is the branch being rebased onto and theirs is the commit being replayed")
+	default:
+		prompt.WriteString(" during a " + operation)
+	}
+	prompt.WriteString(".\n\n")
+	writeCodeBlock(&prompt, "Code before the conflict", before)
+	writeCodeBlock(&prompt, "Ours ("+hunk.OursLabel+")", hunk.Ours)
+	if hunk.Base != nil {
+		writeCodeBlock(&prompt, "Common ancestor", hunk.Base)
+	}
+	writeCodeBlock(&prompt, "Theirs ("+hunk.TheirsLabel+")", hunk.Theirs)
+	writeCodeBlock(&prompt, "Code after the conflict", after)
+	prompt.WriteString("Keep the intent of both sides where possible. Answer with a one or two sentence rationale, ")
+	prompt.WriteString("followed by the resolved lines in a single code block, without conflict markers and without the code before and after the conflict.")
+
+	fmt.Fprintln(os.Stderr, "\nGenerating resolution...")
+	answer, err := ai.GenerateConflictResolution(prompt.String(), config)
+	if err != nil {
+		return nil, "", &generationError{fmt.Errorf("generating resolution: %w", err)}
+	}
+	resolution, rationale, err := parseResolution(answer)
+	if err != nil {
+		return nil, "", &generationError{err}
+	}
+	return resolution, rationale, nil
+}
+
+// writeCodeBlock writes a titled code block to the prompt
+func writeCodeBlock(prompt *strings.Builder, title string, lines []string) {
+	fmt.Fprintf(prompt, "%s:\n```\n%s\n```\n\n", title, strings.Join(lines, "\n"))
+}
+
+// parseResolution splits the model's answer into the lines of the last code block
+// and the rationale before it
+func parseResolution(answer string) ([]string, string, error) {
+	lines := strings.Split(strings.TrimSpace(answer), "\n")
+	end := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			end = i
+			break
+		}
+	}
+	start := -1
+	for i := end - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, "", fmt.Errorf("no code block with the resolution in the answer: %s", answer)
+	}
+
+	rationale := strings.TrimSpace(strings.Join(lines[:start], " "))
+	for _, label := range []string{"Rationale:", "**Rationale:**", "**Rationale**:"} {
+		rationale = strings.TrimSpace(strings.TrimPrefix(rationale, label))
+	}
+	return lines[start+1 : end], rationale, nil
+}
+
+// sideBySide renders two blocks of lines in columns that fit the width
+func sideBySide(leftTitle string, left []string, rightTitle string, right []string, width int) string {
+	column := (width - 3) / 2
+	if column < 20 {
+		column = 20
+	}
+
+	var sb strings.Builder
+	row := func(l, r string) {
+		fmt.Fprintf(&sb, "%s | %s\n", fitColumn(l, column), strings.TrimRight(fitColumn(r, column), " "))
+	}
+	row(leftTitle, rightTitle)
+	row(strings.Repeat("-", column), strings.Repeat("-", column))
+	for i := 0; i < max(len(left), len(right)); i++ {
+		l, r := "", ""
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		row(l, r)
+	}
+	return sb.String()
+}
+
+// fitColumn expands tabs and pads or truncates the text to the width
+func fitColumn(text string, width int) string {
+	text = strings.ReplaceAll(strings.TrimRight(text, "\r"), "\t", "    ")
+	n := utf8.RuneCountInString(text)
+	if n > width {
+		return string([]rune(text)[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-n)
+}
+
+// terminalWidth returns the width of the terminal from $COLUMNS, or 120
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 120
+}
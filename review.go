@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// reviewSeverities are the severities of review findings, from least to most severe
+var reviewSeverities = []string{"info", "warning", "error", "critical"}
+
+// reviewFinding is an issue reported by the review
+type reviewFinding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+// newReviewCmd creates the review command
+func newReviewCmd() *cobra.Command {
+	var failOn string
+
+	cmd := &cobra.Command{
+		Use:   "review [-- <pathspec>...]",
+		Short: "Review the staged changes",
+		Long: "Send the staged diff to the AI model for a code review and print the findings grouped by file. " +
+			"With --fail-on, the command exits with status 1 if a finding is at least that severe, " +
+			"so it can run as a pre-commit hook.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			threshold := -1
+			if failOn != "" {
+				threshold = severityRank(failOn)
+				if threshold < 0 {
+					return fmt.Errorf("unknown severity %q, expected one of %s", failOn, strings.Join(reviewSeverities, ", "))
+				}
+			}
+			return handleReview(loadConfig(), args, threshold)
+		},
+	}
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "exit with status 1 if a finding has this severity or higher ("+strings.Join(reviewSeverities, ", ")+")")
+	return cmd
+}
+
+// handleReview reviews the staged changes and prints the findings. It fails if a
+// finding is at least as severe as the threshold (if threshold >= 0).
+func handleReview(config ai.Config, pathspecs []string, threshold int) error {
+	files, err := git.GetStagedDiff(pathspecs...)
+	if err != nil {
+		return fmt.Errorf("getting staged changes: %w", err)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "No staged changes to review")
+		return nil
+	}
+
+	// Files excluded by the policy never reach the prompt, not even by name
+	policyRules := git.ParseIgnoreRules(config.Policy.ExcludePaths)
+	var reviewed []git.FileDiff
+	for _, file := range files {
+		if !policyRules.MatchFile(file) {
+			reviewed = append(reviewed, file)
+		}
+	}
+	if excluded := len(files) - len(reviewed); excluded > 0 {
+		fmt.Fprintf(os.Stderr, "ai-git policy: %d file(s) excluded from the review by ai-git.excludePaths\n", excluded)
+	}
+
+	rules, err := git.LoadIgnoreRules()
+	if err != nil {
+		return err
+	}
+	reviewed, omitted, err := git.FilterFileDiffs(reviewed, rules)
+	if err != nil {
+		return err
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(os.Stderr, "Not reviewing %d generated or ignored file(s)\n", len(omitted))
+	}
+
+	var findings []reviewFinding
+	batches := batchFileDiffs(reviewed, config.MapReduce.Threshold)
+	for i, batch := range batches {
+		if len(batches) > 1 {
+			fmt.Fprintf(os.Stderr, "Reviewing part %d of %d...\n", i+1, len(batches))
+		} else {
+			fmt.Fprintf(os.Stderr, "Reviewing %d file(s)...\n", len(reviewed))
+		}
+		batchFindings, err := reviewBatch(config, batch)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, batchFindings...)
+	}
+
+	printFindings(findings)
+
+	if threshold >= 0 {
+		failing := 0
+		for _, finding := range findings {
+			if severityRank(finding.Severity) >= threshold {
+				failing++
+			}
+		}
+		if failing > 0 {
+			return fmt.Errorf("%d finding(s) with severity %s or higher", failing, reviewSeverities[threshold])
+		}
+	}
+	return nil
+}
+
+// batchFileDiffs splits the formatted file diffs into batches of at most maxSize
+// bytes. A file larger than maxSize is a batch of its own.
+func batchFileDiffs(files []git.FileDiff, maxSize int) [][]string {
+	var batches [][]string
+	var batch []string
+	size := 0
+	for _, file := range files {
+		formatted := git.FormatFileDiff(file)
+		if len(batch) > 0 && maxSize > 0 && size+len(formatted) > maxSize {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, formatted)
+		size += len(formatted)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// reviewBatch asks the AI model to review the formatted file diffs
+func reviewBatch(config ai.Config, diffs []string) ([]reviewFinding, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Review these staged changes. Each line of the diff starts with its line number, ")
+	prompt.WriteString("the line number in the old file for removed lines and in the new file otherwise.\n\n")
+	prompt.WriteString(strings.Join(diffs, "\n"))
+	fmt.Fprintf(&prompt, "\nAnswer with a JSON array of findings, each an object with the fields \"file\", \"line\", "+
+		"\"severity\" (one of %s), \"message\" and \"suggestion\". ", strings.Join(reviewSeverities, ", "))
+	prompt.WriteString("Answer with [] if there is nothing to report. Just give me the JSON, no explanation needed.")
+
+	answer, err := ai.GenerateReview(prompt.String(), config)
+	if err != nil {
+		return nil, &generationError{fmt.Errorf("generating review: %w", err)}
+	}
+	findings, err := parseFindings(answer)
+	if err != nil {
+		return nil, &generationError{err}
+	}
+	return findings, nil
+}
+
+// parseFindings parses the JSON findings in the model's answer
+func parseFindings(answer string) ([]reviewFinding, error) {
+	answer = trimCodeFence(answer)
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not answer with a list of findings: %s", answer)
+	}
+
+	var findings []reviewFinding
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("parsing the review findings: %w", err)
+	}
+
+	for i := range findings {
+		findings[i].Severity = strings.ToLower(strings.TrimSpace(findings[i].Severity))
+		if severityRank(findings[i].Severity) < 0 {
+			findings[i].Severity = "warning"
+		}
+	}
+	return findings, nil
+}
+
+// printFindings prints the findings grouped by file, ordered by line
+func printFindings(findings []reviewFinding) {
+	if len(findings) == 0 {
+		fmt.Println("No issues found")
+		return
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	counts := make(map[string]int)
+	file := ""
+	for i, finding := range findings {
+		if i == 0 || finding.File != file {
+			file = finding.File
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(file)
+		}
+		location := "-"
+		if finding.Line > 0 {
+			location = fmt.Sprint(finding.Line)
+		}
+		fmt.Printf("  %5s  %-8s  %s\n", location, finding.Severity, finding.Message)
+		if finding.Suggestion != "" {
+			fmt.Printf("  %5s  %-8s  suggestion: %s\n", "", "", finding.Suggestion)
+		}
+		counts[finding.Severity]++
+	}
+
+	var summary []string
+	for i := len(reviewSeverities) - 1; i >= 0; i-- {
+		if n := counts[reviewSeverities[i]]; n > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", n, reviewSeverities[i]))
+		}
+	}
+	fmt.Printf("\n%d finding(s): %s\n", len(findings), strings.Join(summary, ", "))
+}
+
+// severityRank returns the position of a severity in reviewSeverities, or -1
+func severityRank(severity string) int {
+	for i, s := range reviewSeverities {
+		if strings.EqualFold(severity, s) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// rewordEntry is a commit of the range with its current and proposed message
+type rewordEntry struct {
+	git.RevListEntry
+	OldMessage string
+	NewMessage string // Equal to OldMessage for commits that keep their message
+	Merge      bool
+}
+
+// rewordHeaderPattern matches the line that starts a commit in the edited plan
+var rewordHeaderPattern = regexp.MustCompile(`^commit ([0-9a-f]{7,40})$`)
+
+// newRewordCmd creates the reword command
+func newRewordCmd() *cobra.Command {
+	var force, yes, dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "reword <base>..HEAD | <base>",
+		Short: "Regenerate the messages of existing commits",
+		Long: "Generate a new message for each commit after base from the commit's own diff, show the old and " +
+			"new messages for approval and rewrite the history with the approved ones. Commits that are already " +
+			"on a protected branch of a remote (AI_GIT_PROTECTED_BRANCHES) are only rewritten with --force.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			assumeYes = yes
+			return handleReword(loadConfig(), args[0], force, dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "rewrite commits that are already on protected branches")
+	cmd.Flags().BoolVar(&yes, "yes", false, "rewrite with the generated messages without asking")
+	cmd.Flags().BoolVar(&yes, "no-edit", false, "same as --yes")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the generated messages without rewriting")
+	return cmd
+}
+
+// handleReword regenerates the messages of base..HEAD and rewrites the history
+func handleReword(config ai.Config, revRange string, force, dryRun bool) error {
+	base, err := parseHeadRange(revRange)
+	if err != nil {
+		return err
+	}
+
+	revs, err := git.GetRevList(base + "..HEAD")
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	if len(revs) == 0 {
+		return fmt.Errorf("no commits in %s..HEAD", base)
+	}
+	if !dryRun {
+		if err := checkCanConfirm(); err != nil {
+			return err
+		}
+	}
+
+	if !force {
+		pushed, err := git.FindProtectedPushes(base, config.History.ProtectedBranches)
+		if err != nil {
+			return fmt.Errorf("checking protected branches: %w", err)
+		}
+		if len(pushed) > 0 {
+			return fmt.Errorf("commits in %s..HEAD are already on protected branch %s, rewriting them needs a force push; "+
+				"use --force to reword them anyway", base, strings.Join(pushed, ", "))
+		}
+	}
+
+	entries, err := generateRewordMessages(config, revs)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		for _, entry := range entries {
+			if entry.NewMessage != entry.OldMessage {
+				fmt.Printf("commit %s\n%s\n\n", entry.Hash[:7], entry.NewMessage)
+			}
+		}
+		return nil
+	}
+
+	// Ask until the user approves or cancels, editing in between
+	for {
+		printRewordTable(entries)
+		if assumeYes {
+			break
+		}
+		answer := strings.ToLower(promptLine("\nRewrite these commits? [y]es, [e]dit messages, [n]o: "))
+		if answer == "y" || answer == "yes" {
+			break
+		}
+		if answer != "e" && answer != "edit" {
+			fmt.Println("Reword cancelled.")
+			return nil
+		}
+		if err := editRewordMessages(entries); err != nil {
+			return err
+		}
+	}
+
+	return rewriteMessages(entries)
+}
+
+// parseHeadRange returns the base of "<base>..HEAD", "<base>.." or "<base>",
+// checking that it is an ancestor of HEAD
+func parseHeadRange(revRange string) (string, error) {
+	base, to, isRange := strings.Cut(revRange, "..")
+	if isRange && to != "" && to != "HEAD" {
+		return "", fmt.Errorf("only commits up to HEAD can be rewritten, check out %s first", to)
+	}
+	if !git.RevisionExists(base) {
+		return "", fmt.Errorf("unknown revision %s", base)
+	}
+	if !git.IsAncestor(base, "HEAD") {
+		return "", fmt.Errorf("%s is not an ancestor of HEAD", base)
+	}
+	return base, nil
+}
+
+// generateRewordMessages generates a message for each commit from its own diff.
+// Merge commits keep their message.
+func generateRewordMessages(config ai.Config, revs []git.RevListEntry) ([]rewordEntry, error) {
+	entries := make([]rewordEntry, len(revs))
+	for i, rev := range revs {
+		message, err := git.GetCommitMessage(rev.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("reading commit %s: %w", rev.Hash, err)
+		}
+		entries[i] = rewordEntry{RevListEntry: rev, OldMessage: message, NewMessage: message, Merge: len(rev.Parents) > 1}
+		if entries[i].Merge {
+			continue
+		}
+
+		parent := git.EmptyTree
+		if len(rev.Parents) == 1 {
+			parent = rev.Parents[0]
+		}
+		changes, err := git.GetDiffChanges(parent, rev.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("getting the changes of %s: %w", rev.Hash, err)
+		}
+		formattedChanges, err := describeChanges(config, changes)
+		if err != nil {
+			return nil, &generationError{fmt.Errorf("summarizing the changes of %s: %w", rev.Hash, err)}
+		}
+
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "Write a new commit message for an existing commit. Its current message, which may be uninformative, is:\n%s\n\n", message)
+		prompt.WriteString(formattedChanges + "\n")
+		prompt.WriteString("Just give me the commit message, no explanation needed.")
+
+		fmt.Fprintf(os.Stderr, "[%d/%d] Generating message for %s...\n", i+1, len(revs), rev.Hash[:7])
+		generated, err := ai.GenerateCommitMessage(prompt.String(), config)
+		if err != nil {
+			return nil, &generationError{fmt.Errorf("generating the message of %s: %w", rev.Hash, err)}
+		}
+		if generated = trimCodeFence(generated); generated != "" {
+			entries[i].NewMessage = generated
+		}
+	}
+	return entries, nil
+}
+
+// printRewordTable prints the old and new subject of each commit side by side
+func printRewordTable(entries []rewordEntry) {
+	column := (terminalWidth() - 14) / 2
+	if column < 20 {
+		column = 20
+	}
+
+	fmt.Printf("\n%-7s  %s   %s\n", "Commit", fitColumn("Old message", column), "New message")
+	for _, entry := range entries {
+		newSubject := subject(entry.NewMessage)
+		switch {
+		case entry.Merge:
+			newSubject = "(merge, kept)"
+		case entry.NewMessage == entry.OldMessage:
+			newSubject = "(kept)"
+		}
+		fmt.Printf("%-7s  %s   %s\n", entry.Hash[:7], fitColumn(subject(entry.OldMessage), column), strings.TrimRight(fitColumn(newSubject, column), " "))
+	}
+}
+
+// subject returns the first line of a commit message
+func subject(message string) string {
+	first, _, _ := strings.Cut(message, "\n")
+	return first
+}
+
+// editRewordMessages opens the new messages in the editor. A commit whose block
+// is removed or left empty keeps its old message.
+func editRewordMessages(entries []rewordEntry) error {
+	var blocks []string
+	comment := "# AI-generated commit messages. Edit them, save and close the editor to review them again.\n" +
+		"# Remove a commit or clear its message to keep the old message.\n" +
+		"# Lines starting with # will be ignored.\n#\n# Old messages:\n"
+	for _, entry := range entries {
+		comment += fmt.Sprintf("#   %s %s\n", entry.Hash[:7], subject(entry.OldMessage))
+		if !entry.Merge {
+			blocks = append(blocks, fmt.Sprintf("commit %s\n%s", entry.Hash[:7], entry.NewMessage))
+		}
+	}
+
+	edited, err := editText(strings.Join(blocks, "\n\n"), comment, "ai-git-reword-*.txt")
+	if err != nil {
+		return fmt.Errorf("editing commit messages: %w", err)
+	}
+
+	messages := make(map[string]string)
+	current := ""
+	var lines []string
+	flush := func() {
+		if current != "" {
+			messages[current] = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+		lines = nil
+	}
+	for _, line := range strings.Split(edited, "\n") {
+		if match := rewordHeaderPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			flush()
+			current = match[1]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	for i := range entries {
+		if entries[i].Merge {
+			continue
+		}
+		entries[i].NewMessage = entries[i].OldMessage
+		for hash, message := range messages {
+			if strings.HasPrefix(entries[i].Hash, hash) && message != "" {
+				entries[i].NewMessage = message
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteMessages recreates the commits with their new messages, keeping trees,
+// authors and author dates, and moves HEAD to the result. Commits before the first
+// changed message keep their IDs. No ref changes unless all commits were created.
+func rewriteMessages(entries []rewordEntry) error {
+	oldHead, err := git.GetHead()
+	if err != nil {
+		return err
+	}
+
+	rewritten := make(map[string]string)
+	newHead := oldHead
+	changed := 0
+	for _, entry := range entries {
+		parents := make([]string, len(entry.Parents))
+		parentsChanged := false
+		for i, parent := range entry.Parents {
+			parents[i] = parent
+			if newParent, ok := rewritten[parent]; ok {
+				parents[i] = newParent
+				parentsChanged = true
+			}
+		}
+
+		newHash := entry.Hash
+		if parentsChanged || entry.NewMessage != entry.OldMessage {
+			author, err := git.GetAuthor(entry.Hash)
+			if err != nil {
+				return err
+			}
+			newHash, err = git.CommitTree(entry.Hash, parents, author, strings.TrimSpace(entry.NewMessage)+"\n")
+			if err != nil {
+				return fmt.Errorf("rewriting %s: %w", entry.Hash[:7], err)
+			}
+			rewritten[entry.Hash] = newHash
+			if entry.NewMessage != entry.OldMessage {
+				changed++
+			}
+		}
+		newHead = newHash
+	}
+
+	if changed == 0 {
+		fmt.Println("No messages changed.")
+		return nil
+	}
+	if err := git.UpdateRef("HEAD", newHead, oldHead, "ai-git reword"); err != nil {
+		return fmt.Errorf("updating HEAD: %w", err)
+	}
+	fmt.Printf("Reworded %d commit(s). To undo, run \"git reset --soft %s\".\n", changed, oldHead[:7])
+	return nil
+}
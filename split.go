@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+)
+
+// splitUnit is a part of the changes that always goes into a single commit:
+// a hunk of a modified file, or a whole file
+type splitUnit struct {
+	File *git.FileDiff
+	Hunk int  // Index of the hunk in File.Hunks, or -1 for the whole file
+	Hide bool // The file is excluded by the policy and never shown to the model
+}
+
+// commitGroup is a commit of the split plan
+type commitGroup struct {
+	Message string `json:"message"`
+	Units   []int  `json:"units"` // Unit numbers, starting at 1
+}
+
+// planUnitPattern matches a unit line of the plan, e.g. "  3  main.go @@ -1 +1 @@"
+var planUnitPattern = regexp.MustCompile(`^(\d+)(?:\s|$)`)
+
+// handleSplitCommit splits the changes in the working tree into several commits
+// proposed by the AI model. The plan can be edited before anything is committed,
+// and if any commit fails, HEAD and the index are restored.
+func handleSplitCommit(config ai.Config, args gitArgs) {
+	if args.HasOption("--amend") {
+		log.Fatalf("Error: %s cannot be combined with --amend", splitFlag)
+	}
+
+	head, err := git.GetHead()
+	if err != nil {
+		log.Fatalf("Error: %s needs an existing commit to add the new commits to: %v", splitFlag, err)
+	}
+
+	files, err := git.GetWorkingTreeDiff(args.Positional...)
+	if err != nil {
+		log.Fatalf("Error getting git changes: %v", err)
+	}
+
+	units := buildSplitUnits(config, files)
+	if len(units) == 0 {
+		fmt.Println("No changes to commit")
+		return
+	}
+
+	// The model proposes the groups, the user has the final word
+	groups, err := generateSplitPlan(config, units)
+	if err != nil {
+		fatalGeneration("Error generating commit plan: %v", err)
+	}
+	if args.DryRun {
+		fmt.Println(formatSplitPlan(groups, units))
+		return
+	}
+
+	plan, err := editText(formatSplitPlan(groups, units), splitPlanComment(groups, units), "ai-git-split-plan-*.txt")
+	if err != nil {
+		log.Fatalf("Error editing commit plan: %v", err)
+	}
+	if plan == "" {
+		fmt.Println("Commit plan is empty. Commit cancelled.")
+		return
+	}
+	groups, err = parseSplitPlan(plan, len(units))
+	if err != nil {
+		log.Fatalf("Error in commit plan: %v", err)
+	}
+	if len(groups) == 0 {
+		fmt.Println("Commit plan has no commits. Commit cancelled.")
+		return
+	}
+
+	if err := runSplitPlan(args, head, groups, units); err != nil {
+		fatalGit(err, "Error splitting commit: %v", err)
+	}
+}
+
+// buildSplitUnits splits the file diffs into units. Modified text files with
+// several hunks are split into hunks, other files are a single unit.
+func buildSplitUnits(config ai.Config, files []git.FileDiff) []splitUnit {
+	policyRules := git.ParseIgnoreRules(config.Policy.ExcludePaths)
+
+	var units []splitUnit
+	for i := range files {
+		file := &files[i]
+		hide := policyRules.MatchFile(*file)
+		if file.Binary || file.Status != git.Modified || len(file.Hunks) <= 1 {
+			units = append(units, splitUnit{File: file, Hunk: -1, Hide: hide})
+			continue
+		}
+		for j := range file.Hunks {
+			units = append(units, splitUnit{File: file, Hunk: j, Hide: hide})
+		}
+	}
+	return units
+}
+
+// describe returns a one-line description of the unit for the plan
+func (unit splitUnit) describe() string {
+	if unit.Hunk >= 0 {
+		return unit.File.Path + " " + unit.File.Hunks[unit.Hunk].Header
+	}
+	return fmt.Sprintf("%s (%s)", unit.File.Path, unit.File.Status)
+}
+
+// generateSplitPlan asks the AI model to group the units into commits
+func generateSplitPlan(config ai.Config, units []splitUnit) ([]commitGroup, error) {
+	rules, err := git.LoadIgnoreRules()
+	if err != nil {
+		return nil, err
+	}
+	var files []git.FileDiff
+	for _, unit := range units {
+		if unit.Hunk <= 0 && !unit.Hide {
+			files = append(files, *unit.File)
+		}
+	}
+	_, omitted, err := git.FilterFileDiffs(files, rules)
+	if err != nil {
+		return nil, err
+	}
+	omittedSet := make(map[string]bool, len(omitted))
+	for _, path := range omitted {
+		omittedSet[path] = true
+	}
+
+	var formatted []string
+	size := 0
+	for i, unit := range units {
+		if unit.Hide {
+			continue
+		}
+		text := formatSplitUnit(i+1, unit, omittedSet[unit.File.Path])
+		formatted = append(formatted, text)
+		size += len(text)
+	}
+	if len(formatted) == 0 {
+		return nil, fmt.Errorf("all changed files are excluded by ai-git.excludePaths")
+	}
+
+	// Shorten every unit evenly if the prompt would get too large
+	if threshold := config.MapReduce.Threshold; threshold > 0 && size > threshold {
+		limit := threshold / len(formatted)
+		if limit < 300 {
+			limit = 300
+		}
+		for i, text := range formatted {
+			if len(text) > limit {
+				formatted[i] = text[:limit] + "\n... (truncated)\n"
+			}
+		}
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("These uncommitted changes mix unrelated work. Each unit is a file or a hunk of a file:\n\n")
+	prompt.WriteString(strings.Join(formatted, "\n"))
+	prompt.WriteString("\nGroup the units into logically separate commits, in the order they should be committed. ")
+	prompt.WriteString("Answer with a JSON array of commits, each an object with a one-line \"message\" and the list of \"units\" numbers. ")
+	prompt.WriteString("Every unit belongs to exactly one commit. Just give me the JSON, no explanation needed.")
+
+	fmt.Fprintf(os.Stderr, "Grouping %d change(s) into commits...\n", len(formatted))
+	answer, err := ai.GenerateCommitPlan(prompt.String(), config)
+	if err != nil {
+		return nil, err
+	}
+	return parseCommitGroups(answer, units)
+}
+
+// formatSplitUnit formats a unit for the prompt
+func formatSplitUnit(number int, unit splitUnit, omitContent bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Unit %d: %s (%s)\n", number, unit.File.Path, unit.File.Status)
+	switch {
+	case unit.File.Binary:
+		sb.WriteString("Binary file\n")
+	case omitContent:
+		sb.WriteString("Generated or ignored file, content omitted\n")
+	default:
+		hunks := unit.File.Hunks
+		if unit.Hunk >= 0 {
+			hunks = hunks[unit.Hunk : unit.Hunk+1]
+		}
+		for _, hunk := range hunks {
+			sb.WriteString(hunk.Header + "\n")
+			for _, line := range hunk.Lines {
+				if line.Kind != '\\' {
+					sb.WriteString(string(line.Kind) + line.Text + "\n")
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// parseCommitGroups parses the JSON plan in the model's answer. Unknown units and
+// units already assigned to an earlier commit are dropped.
+func parseCommitGroups(answer string, units []splitUnit) ([]commitGroup, error) {
+	answer = trimCodeFence(answer)
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not answer with a list of commits: %s", answer)
+	}
+
+	var groups []commitGroup
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &groups); err != nil {
+		return nil, fmt.Errorf("parsing the commit plan: %w", err)
+	}
+
+	assigned := make(map[int]bool)
+	var valid []commitGroup
+	for _, group := range groups {
+		var groupUnits []int
+		for _, number := range group.Units {
+			if number < 1 || number > len(units) || assigned[number] || units[number-1].Hide {
+				continue
+			}
+			assigned[number] = true
+			groupUnits = append(groupUnits, number)
+		}
+		message := strings.TrimSpace(strings.SplitN(strings.TrimSpace(group.Message), "\n", 2)[0])
+		if len(groupUnits) > 0 && message != "" {
+			valid = append(valid, commitGroup{Message: message, Units: groupUnits})
+		}
+	}
+	return valid, nil
+}
+
+// formatSplitPlan renders the plan for editing
+func formatSplitPlan(groups []commitGroup, units []splitUnit) string {
+	var blocks []string
+	for _, group := range groups {
+		var sb strings.Builder
+		sb.WriteString("commit " + group.Message + "\n")
+		for _, number := range group.Units {
+			fmt.Fprintf(&sb, "%4d  %s\n", number, units[number-1].describe())
+		}
+		blocks = append(blocks, strings.TrimRight(sb.String(), "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// splitPlanComment explains the plan format and lists the units in no commit
+func splitPlanComment(groups []commitGroup, units []splitUnit) string {
+	var sb strings.Builder
+	sb.WriteString("# AI-generated commit plan. The commits are created from top to bottom.\n")
+	sb.WriteString("# Edit the messages after \"commit\", move the numbered changes between commits,\n")
+	sb.WriteString("# or remove a change to leave it uncommitted. Clear the file to cancel.\n")
+	sb.WriteString("# Lines starting with # will be ignored.\n")
+
+	assigned := make(map[int]bool)
+	for _, group := range groups {
+		for _, number := range group.Units {
+			assigned[number] = true
+		}
+	}
+	var unassigned []string
+	for i, unit := range units {
+		if !assigned[i+1] {
+			unassigned = append(unassigned, fmt.Sprintf("#%4d  %s", i+1, unit.describe()))
+		}
+	}
+	if len(unassigned) > 0 {
+		sb.WriteString("#\n# Changes in no commit, remove the # to add them to the commit above:\n")
+		sb.WriteString(strings.Join(unassigned, "\n") + "\n")
+	}
+	return sb.String()
+}
+
+// parseSplitPlan parses the edited plan. Commits without changes are dropped.
+func parseSplitPlan(plan string, unitCount int) ([]commitGroup, error) {
+	var groups []commitGroup
+	assigned := make(map[int]bool)
+
+	for _, line := range strings.Split(plan, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if message, ok := strings.CutPrefix(line, "commit "); ok {
+			groups = append(groups, commitGroup{Message: strings.TrimSpace(message)})
+			continue
+		}
+
+		match := planUnitPattern.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("unexpected line %q, expected \"commit <message>\" or a numbered change", line)
+		}
+		number, _ := strconv.Atoi(match[1])
+		switch {
+		case number < 1 || number > unitCount:
+			return nil, fmt.Errorf("there is no change %d", number)
+		case assigned[number]:
+			return nil, fmt.Errorf("change %d is in more than one commit", number)
+		case len(groups) == 0:
+			return nil, fmt.Errorf("change %d is listed before the first commit", number)
+		}
+		assigned[number] = true
+		groups[len(groups)-1].Units = append(groups[len(groups)-1].Units, number)
+	}
+
+	var valid []commitGroup
+	for _, group := range groups {
+		if len(group.Units) == 0 {
+			continue
+		}
+		if group.Message == "" {
+			return nil, fmt.Errorf("a commit has no message")
+		}
+		valid = append(valid, group)
+	}
+	return valid, nil
+}
+
+// runSplitPlan stages and commits each group on top of head. The index is rebuilt
+// from head, so only the changes of each group end up in its commit. The working
+// tree is never modified. If a step fails, HEAD and the index are restored.
+func runSplitPlan(args gitArgs, head string, groups []commitGroup, units []splitUnit) error {
+	originalIndex, err := git.WriteTree()
+	if err != nil {
+		return fmt.Errorf("saving the index: %w", err)
+	}
+
+	// The whole working tree is split, so -a would put everything in the first commit
+	var options []string
+	for _, option := range args.Options {
+		if option != "-a" && option != "--all" {
+			options = append(options, option)
+		}
+	}
+
+	rollback := func(cause error) error {
+		resetErr := git.ResetSoft(head)
+		readErr := git.ReadTree(originalIndex)
+		if resetErr != nil || readErr != nil {
+			return fmt.Errorf("%w; rolling back also failed, restore with \"git reset --soft %s && git read-tree %s\"", cause, head, originalIndex)
+		}
+		return fmt.Errorf("%w; HEAD and the index were restored", cause)
+	}
+
+	if err := git.ReadTree(head); err != nil {
+		return rollback(fmt.Errorf("resetting the index: %w", err))
+	}
+
+	for i, group := range groups {
+		fmt.Printf("[%d/%d] %s\n", i+1, len(groups), group.Message)
+		if err := stageSplitGroup(group, units); err != nil {
+			return rollback(fmt.Errorf("staging commit %d: %w", i+1, err))
+		}
+		if err := runCommit(gitArgs{Options: options}, group.Message); err != nil {
+			return rollback(fmt.Errorf("creating commit %d: %w", i+1, err))
+		}
+	}
+	return nil
+}
+
+// stageSplitGroup stages the units of a group. Hunks are applied to the index as a
+// patch, whole files are added.
+func stageSplitGroup(group commitGroup, units []splitUnit) error {
+	hunks := make(map[*git.FileDiff][]int)
+	var order []*git.FileDiff
+	var paths []string
+
+	for _, number := range group.Units {
+		unit := units[number-1]
+		if unit.Hunk < 0 {
+			paths = append(paths, unit.File.Path)
+			continue
+		}
+		if _, ok := hunks[unit.File]; !ok {
+			order = append(order, unit.File)
+		}
+		hunks[unit.File] = append(hunks[unit.File], unit.Hunk)
+	}
+
+	var patch strings.Builder
+	for _, file := range order {
+		indexes := hunks[file]
+		sort.Ints(indexes)
+		patch.WriteString(file.Patch(indexes))
+	}
+	if patch.Len() > 0 {
+		if err := git.ApplyToIndex(patch.String()); err != nil {
+			return err
+		}
+	}
+	if len(paths) > 0 {
+		if err := git.StageFiles(paths...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+// newSquashCmd creates the squash command
+func newSquashCmd() *cobra.Command {
+	var yes, dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "squash <base>..HEAD | <base>",
+		Short: "Squash commits into one with a generated message",
+		Long: "Squash the commits after base up to HEAD into a single commit. The message is generated " +
+			"from the combined diff and the original messages, and opens in your editor before the commit is made.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			assumeYes = yes
+			return handleSquash(loadConfig(), args[0], dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "use the generated message without opening the editor")
+	cmd.Flags().BoolVar(&yes, "no-edit", false, "same as --yes")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the generated message without squashing")
+	return cmd
+}
+
+// handleSquash squashes base..HEAD into a single commit on top of base
+func handleSquash(config ai.Config, revRange string, dryRun bool) error {
+	base, err := parseHeadRange(revRange)
+	if err != nil {
+		return err
+	}
+
+	// Staged changes would end up in the squashed commit
+	if err := exec.Command("git", "diff", "--cached", "--quiet").Run(); err != nil {
+		return fmt.Errorf("you have staged changes, commit or unstage them first")
+	}
+
+	commits, err := git.GetCommits(base + "..HEAD")
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	if len(commits) < 2 {
+		return fmt.Errorf("%s..HEAD has %d commit(s), nothing to squash", base, len(commits))
+	}
+
+	changes, err := git.GetDiffChanges(base, "HEAD")
+	if err != nil {
+		return fmt.Errorf("getting git changes: %w", err)
+	}
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		return &generationError{fmt.Errorf("summarizing git changes: %w", err)}
+	}
+
+	// Create prompt
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Write a single commit message for these %d commits that are squashed into one.\n\n", len(commits))
+	prompt.WriteString("Original messages, oldest first:\n")
+	for _, commit := range commits {
+		prompt.WriteString("- " + strings.ReplaceAll(commit.Message(), "\n", "\n  ") + "\n")
+	}
+	prompt.WriteString("\n" + formattedChanges + "\n")
+	prompt.WriteString("Describe the combined result, not the history: leave out fixups, typo fixes and work in progress. ")
+	prompt.WriteString("Just give me the commit message, no explanation needed.")
+
+	fmt.Fprintf(os.Stderr, "Generating message for %d commits...\n", len(commits))
+	message, err := ai.GenerateCommitMessage(prompt.String(), config)
+	if err != nil {
+		return &generationError{fmt.Errorf("generating commit message: %w", err)}
+	}
+	if dryRun {
+		fmt.Println(trimCodeFence(message))
+		return nil
+	}
+
+	comment := fmt.Sprintf("# AI-generated message for %d squashed commits. Save and close the editor to confirm.\n", len(commits)) +
+		"# Or clear the file to cancel the squash.\n# Lines starting with # will be ignored.\n#\n# Squashed commits:\n"
+	for _, commit := range commits {
+		comment += fmt.Sprintf("#   %s %s\n", commit.ShortHash(), commit.Subject)
+	}
+	message, err = editText(trimCodeFence(message), comment, "ai-git-squash-msg-*.txt")
+	if err != nil {
+		return fmt.Errorf("editing commit message: %w", err)
+	}
+	if message == "" {
+		fmt.Println("Commit message is empty. Squash cancelled.")
+		return nil
+	}
+
+	head, err := git.GetHead()
+	if err != nil {
+		return err
+	}
+	if err := git.ResetSoft(base); err != nil {
+		return fmt.Errorf("resetting to %s: %w", base, err)
+	}
+	if err := runCommit(gitArgs{}, message); err != nil {
+		if resetErr := git.ResetSoft(head); resetErr != nil {
+			return fmt.Errorf("creating the squashed commit: %w; restoring HEAD also failed, run \"git reset --soft %s\"", err, head)
+		}
+		return fmt.Errorf("creating the squashed commit: %w; HEAD was restored", err)
+	}
+
+	fmt.Printf("Squashed %d commits. To undo, run \"git reset --soft %s\".\n", len(commits), head[:7])
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+)
+
+// handleStash generates a message for git stash push, or annotates git stash list
+// with summaries when --explain is given
+func handleStash(config ai.Config, args gitArgs) {
+	subcommand := "push"
+	pathspecs := args.Positional
+	if len(pathspecs) > 0 && isStashSubcommand(pathspecs[0]) {
+		subcommand, pathspecs = pathspecs[0], pathspecs[1:]
+	}
+
+	switch {
+	case subcommand == "list" && args.Explain:
+		handleStashList(config, args)
+	case subcommand == "push" && !args.Explain:
+		handleStashPush(config, args, pathspecs)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: AI mode is supported for git stash push and git stash list %s, not for git stash %s\n", explainFlag, subcommand)
+		os.Exit(1)
+	}
+}
+
+// isStashSubcommand reports whether the argument is a git stash subcommand
+func isStashSubcommand(arg string) bool {
+	switch arg {
+	case "push", "save", "list", "show", "drop", "pop", "apply", "branch", "clear", "create", "store":
+		return true
+	}
+	return false
+}
+
+// handleStashPush stashes the changes with a generated message. If the message
+// cannot be generated, the changes are stashed with git's default message.
+func handleStashPush(config ai.Config, args gitArgs, pathspecs []string) {
+	// The hunks are only chosen once git runs, after the message was generated.
+	// --keep-index needs no special care, the staged changes are stashed too.
+	if args.HasOption("--patch") || args.HasOption("-p") {
+		fmt.Fprintln(os.Stderr, "Error: the message cannot be generated for git stash push --patch, since the hunks are chosen afterwards; give one with -m \"message\"")
+		os.Exit(1)
+	}
+	message, err := generateStashMessage(config, args, pathspecs)
+	if args.DryRun {
+		if err != nil {
+			fatalGeneration("Error generating stash message: %v", err)
+		}
+		if message == "" {
+			message = "No local changes to save"
+		}
+		fmt.Println(message)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not generate a stash message, using git's default: %v\n", err)
+		message = ""
+	} else if message != "" {
+		message, err = editText(message, "# AI-generated stash message. Save and close the editor to confirm.\n# Or clear the file to cancel the stash.\n# Lines starting with # will be ignored.", "ai-git-stash-msg-*.txt")
+		if err != nil {
+			log.Fatalf("Error editing stash message: %v", err)
+		}
+		if message == "" {
+			fmt.Println("Stash message is empty. Stash cancelled.")
+			return
+		}
+		// Stash messages are shown on a single line
+		message = strings.Join(strings.Fields(message), " ")
+	}
+
+	stashArgs := append([]string{"stash", "push"}, args.Options...)
+	if message != "" {
+		stashArgs = append(stashArgs, "-m", message)
+	}
+	if len(pathspecs) > 0 {
+		stashArgs = append(stashArgs, "--")
+		stashArgs = append(stashArgs, pathspecs...)
+	}
+	runGit(stashArgs)
+}
+
+// generateStashMessage generates a one line message for the changes that will be
+// stashed. It returns "" without an error when there is nothing to stash.
+func generateStashMessage(config ai.Config, args gitArgs, pathspecs []string) (string, error) {
+	var changes *git.Changes
+	var err error
+	if args.HasOption("--staged") || args.HasOption("-S") {
+		changes, err = git.GetStagedChanges()
+	} else {
+		changes, err = git.GetChanges(pathspecs...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting git changes: %w", err)
+	}
+
+	// Untracked files are only stashed when asked for
+	if !args.HasOption("-u") && !args.HasOption("--include-untracked") && !args.HasOption("-a") && !args.HasOption("--all") {
+		changes.Unknown = nil
+	}
+	if len(changes.Modified) == 0 && len(changes.Added) == 0 && len(changes.Deleted) == 0 && len(changes.Unknown) == 0 {
+		return "", nil
+	}
+
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		return "", fmt.Errorf("summarizing git changes: %w", err)
+	}
+
+	branch, err := git.GetCurrentBranch()
+	if err != nil {
+		branch = "HEAD"
+	}
+	prompt := fmt.Sprintf("Write a short one line description, under 72 characters, of this work in progress on %s that is being stashed, so it can be recognized in the stash list later:\n\n%s\nJust give me the description, no explanation needed.", branch, formattedChanges)
+
+	fmt.Fprintln(os.Stderr, "Generating stash message...")
+	message, err := ai.GenerateStashMessage(prompt, config)
+	if err != nil {
+		return "", err
+	}
+	return trimCodeFence(message), nil
+}
+
+// handleStashList prints the stash list with a summary of each entry. Summaries are
+// cached per stash commit, so only new entries are sent to the model.
+func handleStashList(config ai.Config, args gitArgs) {
+	stashes, err := git.GetStashes(args.Options...)
+	if err != nil {
+		log.Fatalf("Error listing stashes: %v", err)
+	}
+	if len(stashes) == 0 {
+		fmt.Println("No stashes")
+		return
+	}
+
+	for _, stash := range stashes {
+		fmt.Printf("%s: %s\n", stash.Ref, stash.Subject)
+		summary, err := summarizeStash(config, stash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "    Could not summarize %s: %v\n", stash.Ref, err)
+			continue
+		}
+		fmt.Println("    " + strings.ReplaceAll(summary, "\n", "\n    "))
+	}
+}
+
+// summarizeStash returns a short summary of the changes in a stash, from the cache
+// when possible
+func summarizeStash(config ai.Config, stash git.StashEntry) (string, error) {
+	cachePath := stashCachePath(stash, config)
+	if cachePath != "" {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			return string(data), nil
+		}
+	}
+
+	changes, err := git.GetDiffChanges(stash.Base(), stash.Hash)
+	if err != nil {
+		return "", fmt.Errorf("getting the stashed changes: %w", err)
+	}
+	// Untracked files are stored in a third parent, if any
+	if untracked, err := exec.Command("git", "ls-tree", "-r", "--name-only", stash.Hash+"^3").Output(); err == nil {
+		changes.Unknown = strings.Split(strings.TrimRight(string(untracked), "\n"), "\n")
+	}
+	formattedChanges, err := describeChanges(config, changes)
+	if err != nil {
+		return "", fmt.Errorf("summarizing the stashed changes: %w", err)
+	}
+
+	prompt := fmt.Sprintf("Summarize in one or two short sentences what this stashed work in progress contains. It was stashed as %q.\n\n%s\nJust give me the summary, no explanation needed.", stash.Subject, formattedChanges)
+	summary, err := ai.GenerateStashMessage(prompt, config)
+	if err != nil {
+		return "", err
+	}
+	summary = strings.TrimSpace(trimCodeFence(summary))
+
+	if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			_ = os.WriteFile(cachePath, []byte(summary), 0o644)
+		}
+	}
+	return summary, nil
+}
+
+// stashCachePath returns the cache file for a stash summary, or "" if caching is disabled
+func stashCachePath(stash git.StashEntry, config ai.Config) string {
+	if config.MapReduce.CacheDir == "" {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", config.Type, config.ModelName(), stash.Hash)
+	return filepath.Join(config.MapReduce.CacheDir, "stashes", hex.EncodeToString(h.Sum(nil))+".txt")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/Codexiaoyi/ai-git/pkg/ai"
+	"github.com/Codexiaoyi/ai-git/pkg/git"
+)
+
+// handleTagSuggest proposes the next version from the commits since the last
+// release tag, generates the annotated tag message and creates the tag after
+// confirmation
+func handleTagSuggest(config ai.Config, args gitArgs) {
+	if len(args.Positional) > 1 {
+		log.Fatalf("Error: %s takes at most one commit to tag, the tag name is suggested", suggestFlag)
+	}
+	rev := "HEAD"
+	if len(args.Positional) == 1 {
+		rev = args.Positional[0]
+	}
+	if !git.RevisionExists(rev) {
+		log.Fatalf("Error: unknown revision %s", rev)
+	}
+	if !args.DryRun {
+		if err := checkCanConfirm(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	last, found, err := git.FindLastVersion(rev)
+	if err != nil {
+		log.Fatalf("Error listing tags: %v", err)
+	}
+	logArgs := []string{"--no-merges", rev}
+	if found {
+		logArgs = []string{"--no-merges", last.String() + ".." + rev}
+	} else {
+		last = git.Version{Prefix: "v"}
+	}
+	commits, err := git.GetCommits(logArgs...)
+	if err != nil {
+		log.Fatalf("Error listing commits: %v", err)
+	}
+	if len(commits) == 0 {
+		fmt.Printf("No commits since %s, nothing to release.\n", last)
+		return
+	}
+
+	entries, err := classifyCommits(commits)
+	if err != nil {
+		fatalGeneration("Error classifying commits: %v", err)
+	}
+	level, reason := releaseLevel(entries)
+	suggested := last.Bump(level)
+
+	if found {
+		fmt.Printf("%d commit(s) since %s: %s, suggesting a %s release.\n", len(commits), last, reason, level)
+	} else {
+		fmt.Printf("No release tag yet, %d commit(s): %s, suggesting a %s release.\n", len(commits), reason, level)
+	}
+
+	name := ""
+	if !assumeYes && !args.DryRun {
+		name = promptLine(fmt.Sprintf("Version to release [%s]: ", suggested))
+	}
+	if name == "" {
+		name = suggested.String()
+	}
+	if _, ok := git.ParseVersion(name); !ok {
+		log.Fatalf("Error: %s is not a version like %s", name, suggested)
+	}
+	if git.TagExists(name) && !args.HasOption("-f") && !args.HasOption("--force") {
+		log.Fatalf("Error: tag %s already exists", name)
+	}
+
+	changelog := formatChangelog("Release "+name, entries)
+	prompt := fmt.Sprintf("Write the message of the annotated git tag for release %s, from these changes since %s:\n\n%s\n"+
+		"Start with the line \"Release %s\", then a blank line, a short summary of the release and the notable changes as a plain text list. "+
+		"Mention breaking changes first. Do not use Markdown headings. Just give me the tag message, no explanation needed.",
+		name, last, changelog, name)
+
+	fmt.Fprintln(os.Stderr, "Generating tag message...")
+	message, err := ai.GenerateTagMessage(prompt, config)
+	if err != nil {
+		fatalGeneration("Error generating tag message: %v", err)
+	}
+	if args.DryRun {
+		fmt.Printf("%s\n\n%s\n", name, trimCodeFence(message))
+		return
+	}
+
+	comment := fmt.Sprintf("# AI-generated message for tag %s on %s. Save and close the editor to continue.\n# Or clear the file to cancel.\n# Lines starting with # will be ignored.", name, rev)
+	message, err = editText(trimCodeFence(message), comment, "ai-git-tag-msg-*.txt")
+	if err != nil {
+		log.Fatalf("Error editing tag message: %v", err)
+	}
+	if message == "" {
+		fmt.Println("Tag message is empty. Tag cancelled.")
+		return
+	}
+
+	fmt.Printf("\n%s\n\n", message)
+	if !confirm(fmt.Sprintf("Create tag %s on %s? [y/N] ", name, rev), "y", "yes") {
+		fmt.Println("Tag cancelled.")
+		return
+	}
+
+	tagArgs := append([]string{"tag", "-a"}, args.Options...)
+	tagArgs = append(tagArgs, "-m", message, name, rev)
+	tagCmd := exec.Command("git", tagArgs...)
+	tagCmd.Stdout = os.Stdout
+	tagCmd.Stderr = os.Stderr
+	if err := tagCmd.Run(); err != nil {
+		fatalGit(err, "Error executing git tag: %v", err)
+	}
+	fmt.Printf("Created tag %s. Push it with \"git push origin %s\".\n", name, name)
+}
+
+// releaseLevel returns the Semantic Versioning bump for the changelog entries and
+// the reason for it
+func releaseLevel(entries []changelogEntry) (string, string) {
+	breaking, added := 0, 0
+	for _, entry := range entries {
+		if entry.Breaking {
+			breaking++
+		}
+		if entry.Section == "Added" {
+			added++
+		}
+	}
+
+	switch {
+	case breaking > 0:
+		return "major", fmt.Sprintf("%d breaking change(s)", breaking)
+	case added > 0:
+		return "minor", fmt.Sprintf("%d new feature(s)", added)
+	default:
+		return "patch", "only fixes and other changes"
+	}
+}
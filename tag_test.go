@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestReleaseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []changelogEntry
+		want    string
+	}{
+		{"breaking", []changelogEntry{{Section: "Added"}, {Section: "Changed", Breaking: true}}, "major"},
+		{"feature", []changelogEntry{{Section: "Fixed"}, {Section: "Added"}}, "minor"},
+		{"fix", []changelogEntry{{Section: "Fixed"}, {Section: "Changed"}}, "patch"},
+		{"nothing", nil, "patch"},
+	}
+
+	for _, test := range tests {
+		if level, _ := releaseLevel(test.entries); level != test.want {
+			t.Errorf("%s: releaseLevel = %s, want %s", test.name, level, test.want)
+		}
+	}
+}